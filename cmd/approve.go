@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"c7n-helper/pkg/approval"
+	"c7n-helper/pkg/dto"
+	"c7n-helper/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+var approveCmd = &cobra.Command{
+	Use:   "approve",
+	Short: "Show resources from resource file and write time-limited approval file for clean command",
+	Args:  cobra.ExactArgs(0),
+	Run:   approve,
+}
+
+var (
+	approveFile, approveOutput, approveApprover, approveKeyFile *string
+	approveExclude                                              *[]string
+	approveValidFor                                             *time.Duration
+	approveInteractive                                          *bool
+)
+
+func init() {
+	approveFile = approveCmd.Flags().StringP("resource-file", "r", "", "Resource JSON file")
+	_ = approveCmd.MarkFlagRequired("resource-file")
+	_ = approveCmd.MarkFlagFilename("resource-file")
+	approveOutput = approveCmd.Flags().StringP("approval-file", "o", "approval.json", "Approval JSON file")
+	_ = approveCmd.MarkFlagFilename("approval-file")
+	approveApprover = approveCmd.Flags().String("approver", "", "Approver identity")
+	_ = approveCmd.MarkFlagRequired("approver")
+	approveKeyFile = approveCmd.Flags().String("signing-key-file", "", "Signing key file, the approval is signed with HMAC key from "+dto.SigningKeyEnv+" environment variable or the file")
+	_ = approveCmd.MarkFlagFilename("signing-key-file")
+	approveExclude = approveCmd.Flags().StringSlice("exclude", nil, "Names or IDs of resources excluded from approval")
+	approveValidFor = approveCmd.Flags().Duration("valid-for", time.Hour*24, "Approval validity period")
+	approveInteractive = approveCmd.Flags().Bool("interactive", false, "Confirm each resource from standard input")
+	rootCmd.AddCommand(approveCmd)
+}
+
+func approve(_ *cobra.Command, _ []string) {
+	ctx := context.Background()
+	key, err := dto.SigningKey(*approveKeyFile)
+	if err != nil {
+		log.FromContext(ctx).Fatal(err)
+	}
+	if err := approval.Approve(ctx, *approveFile, *approveOutput, *approveApprover, key, *approveValidFor, *approveExclude, *approveInteractive); err != nil {
+		log.FromContext(ctx).Fatal(err)
+	}
+}
@@ -2,10 +2,17 @@ package cmd
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"os"
 	"time"
 
+	"c7n-helper/pkg/approval"
+	"c7n-helper/pkg/aws"
 	"c7n-helper/pkg/cleaner"
+	"c7n-helper/pkg/dto"
 	"c7n-helper/pkg/log"
+	"c7n-helper/pkg/window"
 	"github.com/spf13/cobra"
 )
 
@@ -18,23 +25,123 @@ var cleanCmd = &cobra.Command{
 }
 
 var (
-	cleanFile  *string
-	cleanTries *int
-	cleanRetry *time.Duration
+	cleanFile                                *string
+	cleanTries                               *int
+	cleanRetry                               *time.Duration
+	cleanLogGroups, cleanKMSKeys, cleanZones *bool
+	cleanKMSPendingWindow                    *int32
+	cleanDrain                               *bool
+	cleanDrainTimeout                        *time.Duration
+	cleanQuarantineDays                      *int
+	cleanRequireMark, cleanCanary            *bool
+	cleanMaxDeletions, cleanMaxPerAccount    *int
+	cleanWindows                             *string
+	cleanConcurrency                         *int
+	cleanVerify                              *bool
+	cleanKeyFile, cleanApproval, cleanRunID  *string
+	cleanLeaseDuration                       *time.Duration
+	cleanMaxAge                              *time.Duration
 )
 
+// Exit code of clean command when maintenance window is closed
+const windowClosedExitCode = 3
+
 func init() {
 	cleanFile = cleanCmd.Flags().StringP("resource-file", "r", "", "Resource JSON file")
 	_ = cleanCmd.MarkFlagRequired("resource-file")
 	_ = cleanCmd.MarkFlagFilename("resource-file")
 	cleanTries = cleanCmd.Flags().IntP("tries-count", "t", 5, "Clean tries count")
 	cleanRetry = cleanCmd.Flags().DurationP("retry-duration", "d", time.Minute, "Clean retry pause")
+	cleanLogGroups = cleanCmd.Flags().Bool("delete-log-groups", false, "Delete cluster CloudWatch log groups")
+	cleanKMSKeys = cleanCmd.Flags().Bool("delete-kms-keys", false, "Schedule deletion of cluster secrets encryption KMS keys")
+	cleanKMSPendingWindow = cleanCmd.Flags().Int32("kms-pending-window", 30, "KMS key deletion pending window in days (7-30)")
+	cleanZones = cleanCmd.Flags().Bool("delete-private-zones", false, "Delete Route 53 private hosted zones tagged for the cluster and disassociate other zones from the VPC")
+	cleanDrain = cleanCmd.Flags().Bool("drain-kubernetes", false, "Delete LoadBalancer Services, Ingresses and PVCs through Kubernetes API before EKS cluster deletion")
+	cleanDrainTimeout = cleanCmd.Flags().Duration("drain-timeout", time.Minute*10, "Kubernetes objects deletion timeout")
+	cleanQuarantineDays = cleanCmd.Flags().Int("quarantine-days", 0, "Delete only resources quarantined at least this number of days ago, disabled if zero")
+	cleanRequireMark = cleanCmd.Flags().Bool("require-mark", true, "Delete only resources marked by mark command with passed deletion date (markable types), --require-mark=false disables the check")
+	cleanMaxDeletions = cleanCmd.Flags().Int("max-deletions", 10, "Maximum number of resources deleted per run, resources skipped by approval, mark, quarantine or window checks are not counted, cleanup fails if more resources pass the checks")
+	cleanMaxPerAccount = cleanCmd.Flags().Int("max-deletions-per-account", 5, "Maximum number of resources deleted per account, counted as --max-deletions")
+	cleanCanary = cleanCmd.Flags().Bool("canary", false, "Delete one resource and verify it is gone before deleting the rest resources in parallel")
+	cleanWindows = cleanCmd.Flags().String("windows", "", "Maintenance windows JSON file, deletions are started only inside the windows")
+	_ = cleanCmd.MarkFlagFilename("windows")
+	cleanConcurrency = cleanCmd.Flags().Int("concurrency", 1, "Maximum number of parallel deletions, unlimited if zero, the maintenance window is checked before each deletion start")
+	cleanVerify = cleanCmd.Flags().Bool("verify-signature", true, "Verify the resource file signature with HMAC key from "+dto.SigningKeyEnv+" environment variable or key file, disable with --verify-signature=false for unsigned files")
+	cleanKeyFile = cleanCmd.Flags().String("signing-key-file", "", "Signing key file")
+	_ = cleanCmd.MarkFlagFilename("signing-key-file")
+	cleanMaxAge = cleanCmd.Flags().Duration("max-report-age", 0, "Maximum age of the signed resource file, not checked if zero")
+	cleanApproval = cleanCmd.Flags().String("approval", "", "Approval JSON file from approve command signed with the signing key, only approved resources are deleted")
+	_ = cleanCmd.MarkFlagFilename("approval")
+	cleanRunID = cleanCmd.Flags().String("run-id", "", "Run ID written in resource leases, generated from hostname and time if empty")
+	cleanLeaseDuration = cleanCmd.Flags().Duration("lease-duration", 0, "Best-effort resource lease duration, the lease is renewed while the resource is deleted, disabled if zero")
 	rootCmd.AddCommand(cleanCmd)
 }
 
 func clean(_ *cobra.Command, _ []string) {
 	ctx := context.Background()
-	if err := cleaner.Clean(ctx, *cleanFile, *cleanTries, *cleanRetry); err != nil {
+	opts := aws.DeleteOptions{
+		Tries:            *cleanTries,
+		RetryInterval:    *cleanRetry,
+		LogGroups:        *cleanLogGroups,
+		KMSKeys:          *cleanKMSKeys,
+		KMSPendingWindow: *cleanKMSPendingWindow,
+		PrivateZones:     *cleanZones,
+		DrainKubernetes:  *cleanDrain,
+		DrainTimeout:     *cleanDrainTimeout,
+		QuarantinePeriod: time.Duration(*cleanQuarantineDays) * time.Hour * 24,
+		RequireMark:      *cleanRequireMark,
+		Canary:           *cleanCanary,
+		Limits:           aws.NewDeletionLimits(*cleanMaxDeletions, *cleanMaxPerAccount),
+		Concurrency:      *cleanConcurrency,
+		RunID:            *cleanRunID,
+		LeaseDuration:    *cleanLeaseDuration,
+	}
+	if opts.RunID == "" {
+		opts.RunID = defaultRunID()
+	}
+	if *cleanWindows != "" {
+		schedule, err := window.Load(*cleanWindows)
+		if err != nil {
+			log.FromContext(ctx).Fatal(err)
+		}
+		opts.Window = schedule
+	}
+	verification := dto.Verification{MaxAge: *cleanMaxAge}
+	if *cleanVerify {
+		key, err := dto.SigningKey(*cleanKeyFile)
+		if err != nil {
+			log.FromContext(ctx).Fatal(err)
+		}
+		verification.Key = key
+	}
+	var approved *approval.Approval
+	if *cleanApproval != "" {
+		approved = &approval.Approval{}
+		if err := approved.ReadFromFile(*cleanApproval); err != nil {
+			log.FromContext(ctx).Fatal(err)
+		}
+		// approvals are always signed, the key is read even if the resource file signature is not verified
+		key, err := dto.SigningKey(*cleanKeyFile)
+		if err != nil {
+			log.FromContext(ctx).Fatal(err)
+		}
+		if err := approved.Verify(key); err != nil {
+			log.FromContext(ctx).Fatal(err)
+		}
+	}
+	if err := cleaner.Clean(ctx, *cleanFile, verification, approved, opts); err != nil {
+		if errors.Is(err, window.ErrClosed) {
+			log.FromContext(ctx).Errorf("cleanup stopped, resources are not deleted outside of maintenance window: %s", err.Error())
+			os.Exit(windowClosedExitCode)
+		}
 		log.FromContext(ctx).Fatal(err)
 	}
 }
+
+func defaultRunID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", hostname, time.Now().Unix())
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"c7n-helper/pkg/log"
+	"c7n-helper/pkg/marker"
+	"github.com/spf13/cobra"
+)
+
+var markCmd = &cobra.Command{
+	Use:   "mark",
+	Short: "Tag resources from resource file with scheduled deletion date",
+	Args:  cobra.ExactArgs(0),
+	Run:   mark,
+}
+
+var (
+	markFile  *string
+	markGrace *time.Duration
+)
+
+func init() {
+	markFile = markCmd.Flags().StringP("resource-file", "r", "", "Resource JSON file")
+	_ = markCmd.MarkFlagRequired("resource-file")
+	_ = markCmd.MarkFlagFilename("resource-file")
+	markGrace = markCmd.Flags().Duration("grace", time.Hour*24*7, "Grace period before deletion")
+	rootCmd.AddCommand(markCmd)
+}
+
+func mark(_ *cobra.Command, _ []string) {
+	ctx := context.Background()
+	if err := marker.Mark(ctx, *markFile, *markGrace); err != nil {
+		log.FromContext(ctx).Fatal(err)
+	}
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"context"
+
+	"c7n-helper/pkg/log"
+	"c7n-helper/pkg/orphan"
+	"github.com/spf13/cobra"
+)
+
+var orphansCmd = &cobra.Command{
+	Use:     "orphans",
+	Short:   "Find AWS resources of EKS clusters that no longer exist and save result in resource JSON file",
+	Aliases: []string{"o"},
+	Args:    cobra.ExactArgs(0),
+	Run:     orphans,
+}
+
+var (
+	orphansProfiles, orphansRegions *[]string
+	orphansResult                   *string
+)
+
+func init() {
+	orphansProfiles = orphansCmd.Flags().StringSliceP("profiles", "p", nil, "AWS shared config profiles (default: all profiles from AWS config and credentials files)")
+	orphansRegions = orphansCmd.Flags().StringSliceP("regions", "g", nil, "AWS regions")
+	_ = orphansCmd.MarkFlagRequired("regions")
+	orphansResult = orphansCmd.Flags().StringP("resource-file", "r", "resources.json", "Resource JSON file")
+	_ = orphansCmd.MarkFlagFilename("resource-file")
+	rootCmd.AddCommand(orphansCmd)
+}
+
+func orphans(_ *cobra.Command, _ []string) {
+	ctx := context.Background()
+	if err := orphan.Find(ctx, *orphansProfiles, *orphansRegions, *orphansResult); err != nil {
+		log.FromContext(ctx).Fatal(err)
+	}
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"context"
 
+	"c7n-helper/pkg/dto"
 	"c7n-helper/pkg/log"
 	"c7n-helper/pkg/parser"
 	"github.com/spf13/cobra"
@@ -16,24 +17,66 @@ var parserCmd = &cobra.Command{
 	Run:     parse,
 }
 
-var parseType, parseDir, parsePolicy, parseResult *string
+var (
+	parseType, parseDir, parsePolicy, parseResult, parseKeyFile *string
+	parseDefinitions, parsePathTemplate, parseOnError           *string
+	parseTagSchema, parseDefaults                               *string
+	parseSign, parseCombined                                    *bool
+	parseMaxErrorRatio                                          *float64
+	parseWorkers                                                *int
+)
 
 func init() {
-	parseType = parserCmd.Flags().StringP("type", "t", "", "Cloud resource type (eks, ec2, s3, gke, gce, arg)")
-	_ = parserCmd.MarkFlagRequired("type")
+	parseType = parserCmd.Flags().StringP("type", "t", "", "Cloud resource type (eks, ec2, s3, k8s-ec2, gke, gce, arg or type from definitions file), inferred from c7n metadata if empty")
 	parseDir = parserCmd.Flags().StringP("report-dir", "d", "", "C7N report directory")
 	_ = parserCmd.MarkFlagRequired("report-dir")
 	_ = parserCmd.MarkFlagDirname("report-dir")
-	parsePolicy = parserCmd.Flags().StringP("policy", "p", "", "C7N policy name")
-	_ = parserCmd.MarkFlagRequired("policy")
+	parsePolicy = parserCmd.Flags().StringP("policy", "p", "", "C7N policy name, all policies are parsed to <resource-file>-<policy>.json files if empty")
 	parseResult = parserCmd.Flags().StringP("resource-file", "r", "resources.json", "Resource JSON file")
 	_ = parserCmd.MarkFlagFilename("resource-file")
+	parseSign = parserCmd.Flags().Bool("sign", false, "Sign the resource file with HMAC key from "+dto.SigningKeyEnv+" environment variable or key file")
+	parseKeyFile = parserCmd.Flags().String("signing-key-file", "", "Signing key file")
+	_ = parserCmd.MarkFlagFilename("signing-key-file")
+	parseDefinitions = parserCmd.Flags().String("definitions", "", "Resource type definitions YAML file")
+	_ = parserCmd.MarkFlagFilename("definitions")
+	parseCombined = parserCmd.Flags().Bool("combined", false, "Save all parsed policies to one multi-section resource file")
+	parsePathTemplate = parserCmd.Flags().String("path-template", parser.DefaultPathTemplate, "C7N output layout with {account}, {region}, {date} and {policy} placeholders")
+	parseOnError = parserCmd.Flags().String("on-error", parser.OnErrorFail, "Unreadable or malformed c7n resource file handling: fail, skip or record (saved to report diagnostics)")
+	parseMaxErrorRatio = parserCmd.Flags().Float64("max-error-ratio", 0, "Exit with error after saving the report if the ratio of failed c7n resource files is greater (skip and record modes)")
+	parseTagSchema = parserCmd.Flags().String("tag-schema", "", "Tag schema YAML file with owner, expiry, created, name and team tag keys per cloud")
+	_ = parserCmd.MarkFlagFilename("tag-schema")
+	parseDefaults = parserCmd.Flags().String("defaults", "", "Default TTL policy YAML file of resources without expiry tag, 10 days for gke and gce if empty")
+	_ = parserCmd.MarkFlagFilename("defaults")
+	parseWorkers = parserCmd.Flags().Int("workers", 0, "Number of c7n resource files parsed concurrently, number of CPUs if 0")
 	rootCmd.AddCommand(parserCmd)
 }
 
 func parse(_ *cobra.Command, _ []string) {
 	ctx := context.Background()
-	if err := parser.Parse(ctx, *parseType, *parseDir, *parsePolicy, *parseResult); err != nil {
+	var signingKey []byte
+	if *parseSign {
+		key, err := dto.SigningKey(*parseKeyFile)
+		if err != nil {
+			log.FromContext(ctx).Fatal(err)
+		}
+		signingKey = key
+	}
+	opts := parser.Options{
+		Type:            *parseType,
+		ReportDir:       *parseDir,
+		Policy:          *parsePolicy,
+		ResourceFile:    *parseResult,
+		DefinitionsFile: *parseDefinitions,
+		PathTemplate:    *parsePathTemplate,
+		SigningKey:      signingKey,
+		Combined:        *parseCombined,
+		OnError:         *parseOnError,
+		MaxErrorRatio:   *parseMaxErrorRatio,
+		Workers:         *parseWorkers,
+		TagSchemaFile:   *parseTagSchema,
+		DefaultsFile:    *parseDefaults,
+	}
+	if err := parser.Parse(ctx, opts); err != nil {
 		log.FromContext(ctx).Fatal(err)
 	}
 }
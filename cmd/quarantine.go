@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"context"
+
+	"c7n-helper/pkg/cleaner"
+	"c7n-helper/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+var quarantineCmd = &cobra.Command{
+	Use:   "quarantine",
+	Short: "Restrict EKS API endpoint access and isolate cluster instances from resource file, original settings are saved in tags",
+	Args:  cobra.ExactArgs(0),
+	Run:   quarantine,
+}
+
+var unquarantineCmd = &cobra.Command{
+	Use:   "unquarantine",
+	Short: "Restore EKS API endpoint access and cluster instances security groups changed by quarantine command",
+	Args:  cobra.ExactArgs(0),
+	Run:   unquarantine,
+}
+
+var (
+	quarantineFile, unquarantineFile *string
+	quarantineCidrs                  *[]string
+)
+
+func init() {
+	quarantineFile = quarantineCmd.Flags().StringP("resource-file", "r", "", "Resource JSON file")
+	_ = quarantineCmd.MarkFlagRequired("resource-file")
+	_ = quarantineCmd.MarkFlagFilename("resource-file")
+	quarantineCidrs = quarantineCmd.Flags().StringSlice("public-access-cidrs", []string{"127.0.0.1/32"}, "CIDRs allowed to access EKS public API endpoint while quarantined")
+	rootCmd.AddCommand(quarantineCmd)
+	unquarantineFile = unquarantineCmd.Flags().StringP("resource-file", "r", "", "Resource JSON file")
+	_ = unquarantineCmd.MarkFlagRequired("resource-file")
+	_ = unquarantineCmd.MarkFlagFilename("resource-file")
+	rootCmd.AddCommand(unquarantineCmd)
+}
+
+func quarantine(_ *cobra.Command, _ []string) {
+	ctx := context.Background()
+	if err := cleaner.Quarantine(ctx, *quarantineFile, *quarantineCidrs); err != nil {
+		log.FromContext(ctx).Fatal(err)
+	}
+}
+
+func unquarantine(_ *cobra.Command, _ []string) {
+	ctx := context.Background()
+	if err := cleaner.Unquarantine(ctx, *unquarantineFile); err != nil {
+		log.FromContext(ctx).Fatal(err)
+	}
+}
@@ -16,7 +16,10 @@ var slackCmd = &cobra.Command{
 	Run:     notify,
 }
 
-var slackResourceFile, slackToken, slackChannel, slackTitle *string
+var (
+	slackResourceFile, slackToken, slackChannel, slackTitle *string
+	slackExpectedAccounts, slackExpectedRegions             *[]string
+)
 
 func init() {
 	slackResourceFile = slackCmd.Flags().StringP("resource-file", "r", "resources.json", "Resource JSON file")
@@ -26,12 +29,14 @@ func init() {
 	slackChannel = slackCmd.Flags().StringP("channel", "c", "", "Slack default channel ID")
 	_ = slackCmd.MarkFlagRequired("channel")
 	slackTitle = slackCmd.Flags().StringP("title", "t", "", "Slack notification title")
+	slackExpectedAccounts = slackCmd.Flags().StringSlice("expected-accounts", nil, "Accounts expected in each policy run, missing ones are reported to the default channel")
+	slackExpectedRegions = slackCmd.Flags().StringSlice("expected-regions", nil, "Regions expected in each policy run, missing ones are reported to the default channel")
 	rootCmd.AddCommand(slackCmd)
 }
 
 func notify(_ *cobra.Command, _ []string) {
 	ctx := context.Background()
-	if err := slack.Notify(ctx, *slackResourceFile, *slackToken, *slackChannel, *slackTitle); err != nil {
+	if err := slack.Notify(ctx, *slackResourceFile, *slackToken, *slackChannel, *slackTitle, *slackExpectedAccounts, *slackExpectedRegions); err != nil {
 		log.FromContext(ctx).Fatal(err)
 	}
 }
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"context"
+
+	"c7n-helper/pkg/cleaner"
+	"c7n-helper/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+var stopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Scale EKS clusters to zero and stop EC2 instances from resource file, original capacity is saved in tags",
+	Args:  cobra.ExactArgs(0),
+	Run:   stop,
+}
+
+var startCmd = &cobra.Command{
+	Use:   "start",
+	Short: "Restore EKS clusters capacity and start EC2 instances stopped by stop command",
+	Args:  cobra.ExactArgs(0),
+	Run:   start,
+}
+
+var stopFile, startFile *string
+
+func init() {
+	stopFile = stopCmd.Flags().StringP("resource-file", "r", "", "Resource JSON file")
+	_ = stopCmd.MarkFlagRequired("resource-file")
+	_ = stopCmd.MarkFlagFilename("resource-file")
+	rootCmd.AddCommand(stopCmd)
+	startFile = startCmd.Flags().StringP("resource-file", "r", "", "Resource JSON file")
+	_ = startCmd.MarkFlagRequired("resource-file")
+	_ = startCmd.MarkFlagFilename("resource-file")
+	rootCmd.AddCommand(startCmd)
+}
+
+func stop(_ *cobra.Command, _ []string) {
+	ctx := context.Background()
+	if err := cleaner.Stop(ctx, *stopFile); err != nil {
+		log.FromContext(ctx).Fatal(err)
+	}
+}
+
+func start(_ *cobra.Command, _ []string) {
+	ctx := context.Background()
+	if err := cleaner.Start(ctx, *startFile); err != nil {
+		log.FromContext(ctx).Fatal(err)
+	}
+}
@@ -0,0 +1,235 @@
+package approval
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"c7n-helper/pkg/dto"
+	"c7n-helper/pkg/expiry"
+	"github.com/lensesio/tableprinter"
+)
+
+// Approval is the list of resources approved for deletion by a person until the expiry time
+type Approval struct {
+	Approver  string     `json:"approver"`
+	Approved  time.Time  `json:"approved"`
+	Expires   time.Time  `json:"expires"`
+	Resources []Resource `json:"resources"`
+	// HMAC-SHA256 signature with the resource file signing key, see dto.Signature
+	Signature string `json:"signature,omitempty"`
+	// JSON content the approval was read from, the signature is verified against it
+	raw []byte
+}
+
+type Resource struct {
+	Type     string `json:"type"`
+	Account  string `json:"account"`
+	Location string `json:"location"`
+	Name     string `json:"name"`
+	Digest   string `json:"digest"`
+}
+
+type line struct {
+	Index    int    `header:"#"`
+	Type     string `header:"Type"`
+	Account  string `header:"Account"`
+	Region   string `header:"Region"`
+	Name     string `header:"Name"`
+	Owner    string `header:"Owner"`
+	Expiry   string `header:"Expiry date"`
+	Approved string `header:"Approved"`
+}
+
+// Digest identifies the resource, changes of the resource identity or owner in the report invalidate the approval.
+// Resource type is case-insensitive like in the commands using the report.
+func Digest(resourceType, account string, resource dto.Resource) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		strings.ToLower(resourceType), account, resource.Location, resource.Name, resource.ID, resource.Owner,
+	}, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// New approves resources of all report sections except the excluded ones
+func New(report dto.Report, approver string, validFor time.Duration, exclude func(account string, resource dto.Resource) bool) Approval {
+	now := time.Now().UTC()
+	result := Approval{
+		Approver:  approver,
+		Approved:  now,
+		Expires:   now.Add(validFor),
+		Resources: make([]Resource, 0),
+	}
+	for _, section := range report.Sections {
+		for _, account := range section.Accounts {
+			for _, resource := range account.Resources {
+				if exclude != nil && exclude(account.Name, resource) {
+					continue
+				}
+				result.Resources = append(result.Resources, Resource{
+					Type:     section.Type,
+					Account:  account.Name,
+					Location: resource.Location,
+					Name:     resource.Name,
+					Digest:   Digest(section.Type, account.Name, resource),
+				})
+			}
+		}
+	}
+	return result
+}
+
+// Check fails if the approval is expired
+func (a *Approval) Check() error {
+	if time.Now().After(a.Expires) {
+		return fmt.Errorf("approval by %s expired at %s", a.Approver, a.Expires.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Filter returns report section accounts with approved resources only
+func (a *Approval) Filter(section dto.PolicyReport) []dto.Account {
+	approved := a.digests()
+	accounts := make([]dto.Account, 0, len(section.Accounts))
+	for _, account := range section.Accounts {
+		resources := make([]dto.Resource, 0, len(account.Resources))
+		for _, resource := range account.Resources {
+			if _, ok := approved[Digest(section.Type, account.Name, resource)]; ok {
+				resources = append(resources, resource)
+			}
+		}
+		if len(resources) > 0 {
+			accounts = append(accounts, dto.Account{Name: account.Name, Resources: resources})
+		}
+	}
+	return accounts
+}
+
+func (a *Approval) digests() map[string]struct{} {
+	approved := make(map[string]struct{}, len(a.Resources))
+	for _, resource := range a.Resources {
+		approved[resource.Digest] = struct{}{}
+	}
+	return approved
+}
+
+// Sign sets the approval signature, the key is the resource file signing key
+func (a *Approval) Sign(key []byte) error {
+	a.Signature = ""
+	content, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	a.Signature, err = dto.Signature(key, content)
+	return err
+}
+
+// Verify fails if the approval is not signed with the key or was changed after signing
+func (a *Approval) Verify(key []byte) error {
+	if a.Signature == "" {
+		return errors.New("approval file is not signed")
+	}
+	content := a.raw
+	if content == nil {
+		var err error
+		if content, err = json.Marshal(a); err != nil {
+			return err
+		}
+	}
+	expected, err := dto.Signature(key, content)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(expected), []byte(a.Signature)) {
+		return errors.New("approval file signature is invalid")
+	}
+	return nil
+}
+
+func (a *Approval) ReadFromFile(file string) error {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(content, a); err != nil {
+		return err
+	}
+	a.raw = content
+	return nil
+}
+
+func (a *Approval) WriteToFile(file string) error {
+	content, err := json.MarshalIndent(a, "", " ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, content, 0644)
+}
+
+// Print writes the report resources table marking the approved ones
+func (a *Approval) Print(w io.Writer, report dto.Report) {
+	approved := a.digests()
+	printLines(w, report, func(digest string) string {
+		if _, ok := approved[digest]; ok {
+			return "yes"
+		}
+		return "no"
+	})
+}
+
+// PrintPending writes the report resources table before the approval decisions
+func PrintPending(w io.Writer, report dto.Report) {
+	printLines(w, report, func(string) string {
+		return "pending"
+	})
+}
+
+func printLines(w io.Writer, report dto.Report, approvedMark func(digest string) string) {
+	lines := make([]line, 0)
+	for _, section := range report.Sections {
+		for _, account := range section.Accounts {
+			for _, resource := range account.Resources {
+				mark := approvedMark(Digest(section.Type, account.Name, resource))
+				lines = append(lines, line{
+					Index:    len(lines) + 1,
+					Type:     section.Type,
+					Account:  account.Name,
+					Region:   resource.Location,
+					Name:     resource.Name,
+					Owner:    resource.Owner,
+					Expiry:   expiry.Format(resource.Expiry, resource.ExpiryStatus),
+					Approved: mark,
+				})
+			}
+		}
+	}
+	tableprinter.Print(w, lines)
+}
+
+// Prompt asks to approve each resource, returns exclude function for New
+func Prompt(in io.Reader, out io.Writer) func(account string, resource dto.Resource) bool {
+	reader := bufio.NewReader(in)
+	return func(account string, resource dto.Resource) bool {
+		for {
+			_, _ = fmt.Fprintf(out, "approve deletion of %s/%s/%s owned by %q? [y/n]: ", account, resource.Location, resource.Name, resource.Owner)
+			answer, err := reader.ReadString('\n')
+			switch strings.ToLower(strings.TrimSpace(answer)) {
+			case "y", "yes":
+				return false
+			case "n", "no":
+				return true
+			}
+			if errors.Is(err, io.EOF) {
+				// no more input, do not approve the rest
+				return true
+			}
+		}
+	}
+}
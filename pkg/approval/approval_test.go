@@ -0,0 +1,100 @@
+package approval_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"c7n-helper/pkg/approval"
+	"c7n-helper/pkg/dto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilter(t *testing.T) {
+	report := dto.Report{Sections: []dto.PolicyReport{{
+		Type: "eks",
+		Accounts: []dto.Account{
+			{Name: "dev", Resources: []dto.Resource{{Name: "cluster-1", Location: "us-east-1"}, {Name: "cluster-2", Location: "us-east-1"}}},
+			{Name: "prod", Resources: []dto.Resource{{Name: "cluster-3", Location: "eu-west-1"}}},
+		},
+	}}}
+	a := approval.New(report, "alice", time.Hour, func(account string, resource dto.Resource) bool {
+		return resource.Name == "cluster-2"
+	})
+	assert.Len(t, a.Resources, 2)
+	assert.NoError(t, a.Check())
+
+	section := report.Sections[0]
+	section.Accounts = append(section.Accounts, dto.Account{Name: "qa", Resources: []dto.Resource{{Name: "cluster-4", Location: "us-east-1"}}})
+	section.Accounts[1].Resources[0].Owner = "bob"
+	accounts := a.Filter(section)
+	assert.Len(t, accounts, 1)
+	assert.Equal(t, "dev", accounts[0].Name)
+	assert.Equal(t, []dto.Resource{{Name: "cluster-1", Location: "us-east-1"}}, accounts[0].Resources)
+
+	section.Type = "ec2"
+	assert.Empty(t, a.Filter(section))
+
+	a.Expires = time.Now().Add(-time.Minute)
+	assert.Error(t, a.Check())
+}
+
+func TestFilterTypeCase(t *testing.T) {
+	// approve keeps the type of the report, clean lowercases it before filtering
+	report := dto.Report{Sections: []dto.PolicyReport{{
+		Type:     "EKS",
+		Accounts: []dto.Account{{Name: "dev", Resources: []dto.Resource{{Name: "cluster-1", Location: "us-east-1"}}}},
+	}}}
+	a := approval.New(report, "alice", time.Hour, func(string, dto.Resource) bool { return false })
+
+	section := report.Sections[0]
+	section.Type = "eks"
+	assert.Len(t, a.Filter(section), 1)
+	assert.Equal(t, approval.Digest("EKS", "dev", section.Accounts[0].Resources[0]), a.Resources[0].Digest)
+}
+
+func TestPrompt(t *testing.T) {
+	var out strings.Builder
+	exclude := approval.Prompt(strings.NewReader("y\nmaybe\nn\n"), &out)
+	assert.False(t, exclude("dev", dto.Resource{Name: "cluster-1"}))
+	assert.True(t, exclude("dev", dto.Resource{Name: "cluster-2"}))
+	// no more input
+	assert.True(t, exclude("dev", dto.Resource{Name: "cluster-3"}))
+}
+
+func TestSignAndVerify(t *testing.T) {
+	report := dto.Report{Sections: []dto.PolicyReport{{
+		Type:     "eks",
+		Accounts: []dto.Account{{Name: "dev", Resources: []dto.Resource{{Name: "cluster-1", Location: "us-east-1"}}}},
+	}}}
+	a := approval.New(report, "alice", time.Hour, nil)
+	assert.Error(t, a.Verify([]byte("secret")))
+	assert.NoError(t, a.Sign([]byte("secret")))
+	file := filepath.Join(t.TempDir(), "approval.json")
+	assert.NoError(t, a.WriteToFile(file))
+
+	var read approval.Approval
+	assert.NoError(t, read.ReadFromFile(file))
+	assert.NoError(t, read.Verify([]byte("secret")))
+	assert.Error(t, read.Verify([]byte("another")))
+
+	content, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(file, []byte(strings.Replace(string(content), "alice", "mallory", 1)), 0644))
+	var changed approval.Approval
+	assert.NoError(t, changed.ReadFromFile(file))
+	assert.Error(t, changed.Verify([]byte("secret")))
+}
+
+func TestPrintPending(t *testing.T) {
+	report := dto.Report{Sections: []dto.PolicyReport{{
+		Type:     "eks",
+		Accounts: []dto.Account{{Name: "dev", Resources: []dto.Resource{{Name: "cluster-1", Location: "us-east-1"}}}},
+	}}}
+	var out strings.Builder
+	approval.PrintPending(&out, report)
+	assert.Contains(t, out.String(), "cluster-1")
+	assert.Contains(t, out.String(), "pending")
+}
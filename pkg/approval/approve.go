@@ -0,0 +1,54 @@
+package approval
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"c7n-helper/pkg/dto"
+	"c7n-helper/pkg/log"
+)
+
+// Approve prints the report resources and writes the approval file signed with the key without the excluded
+// (by name or ID) resources. In interactive mode the resources are printed first and each of them is confirmed
+// from the standard input.
+func Approve(ctx context.Context, resourceFile, approvalFile, approver string, key []byte, validFor time.Duration, excluded []string, interactive bool) error {
+	logger := log.FromContext(ctx)
+	if approver == "" {
+		return errors.New("approver is not set")
+	}
+	if validFor <= 0 {
+		return errors.New("approval validity period must be positive")
+	}
+	logger.Info("reading resource file...")
+	var report dto.Report
+	if err := report.ReadFromFile(resourceFile); err != nil {
+		return err
+	}
+	excludedSet := make(map[string]struct{}, len(excluded))
+	for _, e := range excluded {
+		excludedSet[e] = struct{}{}
+	}
+	var prompt func(account string, resource dto.Resource) bool
+	if interactive {
+		PrintPending(os.Stdout, report)
+		prompt = Prompt(os.Stdin, os.Stderr)
+	}
+	approval := New(report, approver, validFor, func(account string, resource dto.Resource) bool {
+		if _, ok := excludedSet[resource.Name]; ok {
+			return true
+		}
+		if _, ok := excludedSet[resource.ID]; ok && resource.ID != "" {
+			return true
+		}
+		return prompt != nil && prompt(account, resource)
+	})
+	approval.Print(os.Stdout, report)
+	if err := approval.Sign(key); err != nil {
+		return err
+	}
+	logger.Infof("saving approval of %d resources by %s valid until %s...",
+		len(approval.Resources), approval.Approver, approval.Expires.Format(time.RFC3339))
+	return approval.WriteToFile(approvalFile)
+}
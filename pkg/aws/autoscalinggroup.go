@@ -23,17 +23,7 @@ func deleteAutoScalingGroups(ctx context.Context, client *autoscaling.Client, ec
 			continue
 		}
 		// Resize the AutoScalingGroup to zero if not already zero.
-		if (autoScalingGroup.DesiredCapacity != nil && *autoScalingGroup.DesiredCapacity != 0) ||
-			(autoScalingGroup.MaxSize != nil && *autoScalingGroup.MaxSize != 0) ||
-			(autoScalingGroup.MinSize != nil && *autoScalingGroup.MinSize != 0) {
-			_, err := client.UpdateAutoScalingGroup(ctx, &autoscaling.UpdateAutoScalingGroupInput{
-				AutoScalingGroupName: autoScalingGroup.AutoScalingGroupName,
-				DesiredCapacity:      aws.Int32(0),
-				MaxSize:              aws.Int32(0),
-				MinSize:              aws.Int32(0),
-			})
-			errs = multierr.Append(errs, err)
-		}
+		errs = multierr.Append(errs, scaleAutoScalingGroupToZero(ctx, client, autoScalingGroup))
 		// Wait for any Instances to terminate.
 		instanceIds := make([]string, 0, len(autoScalingGroup.Instances))
 		for _, instance := range autoScalingGroup.Instances {
@@ -66,6 +56,25 @@ func deleteAutoScalingGroups(ctx context.Context, client *autoscaling.Client, ec
 	return
 }
 
+func scaleAutoScalingGroupToZero(ctx context.Context, client *autoscaling.Client, autoScalingGroup types.AutoScalingGroup) error {
+	if isAutoScalingGroupScaledToZero(autoScalingGroup) {
+		return nil
+	}
+	_, err := client.UpdateAutoScalingGroup(ctx, &autoscaling.UpdateAutoScalingGroupInput{
+		AutoScalingGroupName: autoScalingGroup.AutoScalingGroupName,
+		DesiredCapacity:      aws.Int32(0),
+		MaxSize:              aws.Int32(0),
+		MinSize:              aws.Int32(0),
+	})
+	return err
+}
+
+func isAutoScalingGroupScaledToZero(autoScalingGroup types.AutoScalingGroup) bool {
+	return aws.ToInt32(autoScalingGroup.DesiredCapacity) == 0 &&
+		aws.ToInt32(autoScalingGroup.MaxSize) == 0 &&
+		aws.ToInt32(autoScalingGroup.MinSize) == 0
+}
+
 func listAutoScalingGroups(ctx context.Context, client *autoscaling.Client, clusterName string) ([]types.AutoScalingGroup, error) {
 	autoScalingGroups := make([]types.AutoScalingGroup, 0)
 	groupNames := map[string]struct{}{}
@@ -114,10 +123,10 @@ func autoScalingFilters(clusterName string) [][]types.Filter {
 
 func describeAutoScalingGroups(ctx context.Context, client *autoscaling.Client, filters []types.Filter) ([]types.AutoScalingGroup, error) {
 	var autoScalingGroups []types.AutoScalingGroup
+	input := &autoscaling.DescribeAutoScalingGroupsInput{
+		Filters: filters,
+	}
 	for {
-		input := &autoscaling.DescribeAutoScalingGroupsInput{
-			Filters: filters,
-		}
 		output, err := client.DescribeAutoScalingGroups(ctx, input)
 		if err != nil {
 			return nil, err
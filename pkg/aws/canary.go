@@ -0,0 +1,98 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"c7n-helper/pkg/dto"
+	"c7n-helper/pkg/log"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// Checks the resource is deleted
+type resourceVerifier func(ctx context.Context, clients *clients, resource dto.Resource) error
+
+var resourceVerifiers = map[string]resourceVerifier{
+	"eks":        verifyEKSClusterDeleted,
+	"k8s-ec2":    verifyK8sEC2ClusterDeleted,
+	"eks-orphan": verifyK8sEC2ClusterDeleted,
+}
+
+// Deletes the first deletable resource and verifies it is gone, then deletes the rest resources in parallel
+func deleteWithCanary(ctx context.Context, resourceType string, accounts []dto.Account, opts DeleteOptions, deleteFn gatedDeleteFn) error {
+	verifier, ok := resourceVerifiers[resourceType]
+	if !ok {
+		return errors.New("unsupported resource type")
+	}
+	rest := make([]dto.Account, 0, len(accounts))
+	canaryDone := false
+	for _, account := range accounts {
+		restAccount := dto.Account{Name: account.Name, Resources: make([]dto.Resource, 0, len(account.Resources))}
+		for _, resource := range account.Resources {
+			if canaryDone {
+				restAccount.Resources = append(restAccount.Resources, resource)
+				continue
+			}
+			key := clientKey(account.Name, resource.Location)
+			cls := clientsMap[key]
+			ctx, logger := log.UpdateContext(ctx, "account:region", key, resourceType, resource.Name)
+			logger.Info("trying canary resource")
+			deleted, err := deleteFn(ctx, cls, resource)
+			if err != nil {
+				return fmt.Errorf("canary %s: %w", resource.Name, err)
+			}
+			if !deleted {
+				continue
+			}
+			logger.Info("verifying canary resource is deleted")
+			if err := withRetries(ctx, opts, func() error { return verifier(ctx, cls, resource) }); err != nil {
+				return fmt.Errorf("canary %s: %w", resource.Name, err)
+			}
+			logger.Info("canary resource is deleted, continuing with the rest resources")
+			canaryDone = true
+		}
+		rest = append(rest, restAccount)
+	}
+	return forEachResource(ctx, resourceType, rest, func(ctx context.Context, cls *clients, resource dto.Resource) error {
+		_, err := deleteFn(ctx, cls, resource)
+		return err
+	})
+}
+
+func verifyEKSClusterDeleted(ctx context.Context, clients *clients, resource dto.Resource) error {
+	cluster, err := listEKS(ctx, clients.EKS, resource.Name)
+	if errors.As(err, &eksNotFoundErr) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("cluster still exists with status %s", cluster.Status)
+}
+
+func verifyK8sEC2ClusterDeleted(ctx context.Context, clients *clients, resource dto.Resource) error {
+	vpcIDs, err := listClusterVpcs(ctx, clients.EC2, resource.Name)
+	if err != nil {
+		return err
+	}
+	if len(vpcIDs) > 0 {
+		return fmt.Errorf("cluster vpc still exists: %v", vpcIDs)
+	}
+	reservations, err := listClusterReservations(ctx, clients.EC2, resource.Name)
+	if err != nil {
+		return err
+	}
+	for _, reservation := range reservations {
+		for _, instance := range reservation.Instances {
+			if !isInstanceTerminated(instance) {
+				return fmt.Errorf("cluster instance still exists: %s", *instance.InstanceId)
+			}
+		}
+	}
+	return nil
+}
+
+func isInstanceTerminated(instance types.Instance) bool {
+	return instance.State != nil && instance.State.Name == types.InstanceStateNameTerminated
+}
@@ -3,52 +3,233 @@ package aws
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"c7n-helper/pkg/dto"
 	"c7n-helper/pkg/log"
+	"c7n-helper/pkg/window"
 	"github.com/hashicorp/go-multierror"
 	"go.uber.org/multierr"
 )
 
-func DeleteResources(ctx context.Context, accounts []dto.Account, tries int, retryInterval time.Duration) error {
+type DeleteOptions struct {
+	Tries         int
+	RetryInterval time.Duration
+	// Opt-in deletion of cluster resources outside the VPC
+	LogGroups        bool
+	KMSKeys          bool
+	KMSPendingWindow int32 // days
+	PrivateZones     bool
+	// Deletes Kubernetes objects owning cloud resources through the cluster API before the cluster deletion
+	DrainKubernetes bool
+	DrainTimeout    time.Duration
+	// Deletes only resources quarantined at least the period ago, disabled if zero
+	QuarantinePeriod time.Duration
+	// Deletes only resources marked by mark command with passed deletion date
+	RequireMark bool
+	// Deletes one resource and verifies it is gone before deleting the rest resources
+	Canary bool
+	// Limits of deleted resources count shared by all sections of the run, unlimited if nil
+	Limits *DeletionLimits
+	// Maintenance window checked before each resource deletion, not checked if nil
+	Window *window.Schedule
+	// Maximum number of parallel deletions, unlimited if zero
+	Concurrency int
+	// Resources are leased by the run with tags to skip resources deleted by concurrent runs, disabled if zero
+	RunID         string
+	LeaseDuration time.Duration
+}
+
+type resourceDeleter func(ctx context.Context, clients *clients, resource dto.Resource, opts DeleteOptions) error
+
+var resourceDeleters = map[string]resourceDeleter{
+	"eks":        deleteEKSCluster,
+	"k8s-ec2":    deleteK8sEC2Cluster,
+	"eks-orphan": deleteOrphanCluster,
+}
+
+func IsDeletable(resourceType string) bool {
+	_, ok := resourceDeleters[resourceType]
+	return ok
+}
+
+func DeleteResources(ctx context.Context, resourceType string, accounts []dto.Account, opts DeleteOptions) error {
+	deleter, ok := resourceDeleters[resourceType]
+	if !ok {
+		return errors.New("unsupported resource type")
+	}
+	deleteFn := gatedDelete(resourceType, opts, deleter)
+	if opts.Canary {
+		return deleteWithCanary(ctx, resourceType, accounts, opts, deleteFn)
+	}
+	return forEachResource(ctx, resourceType, accounts, func(ctx context.Context, cls *clients, resource dto.Resource) error {
+		_, err := deleteFn(ctx, cls, resource)
+		return err
+	})
+}
+
+// Deletes the resource if allowed by the options, returns false if the resource is skipped
+type gatedDeleteFn func(ctx context.Context, cls *clients, resource dto.Resource) (bool, error)
+
+// Limits parallel deletions by the options concurrency and checks maintenance window before each deletion
+func gatedDelete(resourceType string, opts DeleteOptions, deleter resourceDeleter) gatedDeleteFn {
+	var semaphore chan struct{}
+	if opts.Concurrency > 0 {
+		semaphore = make(chan struct{}, opts.Concurrency)
+	}
+	return func(ctx context.Context, cls *clients, resource dto.Resource) (bool, error) {
+		if semaphore != nil {
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+		}
+		if opts.Window != nil {
+			if err := opts.Window.Check(time.Now()); err != nil {
+				log.FromContext(ctx).Warnf("not starting deletion: %s", err.Error())
+				return false, err
+			}
+		}
+		allowed, err := isDeletionAllowed(ctx, cls, resourceType, resource, opts)
+		if err != nil || !allowed {
+			return false, err
+		}
+		deleteCtx := ctx
+		if opts.LeaseDuration > 0 {
+			leaseCtx, release, ok, err := takeLease(ctx, cls, resourceType, resource, opts)
+			if err != nil || !ok {
+				return false, err
+			}
+			defer release()
+			deleteCtx = leaseCtx
+		}
+		if err := opts.Limits.take(cls.Account); err != nil {
+			log.FromContext(ctx).Warnf("not starting deletion: %s", err.Error())
+			return false, err
+		}
+		err = deleter(deleteCtx, cls, resource, opts)
+		// the deletion is stopped by cancelled requests, report the lost lease instead of their errors
+		if cause := context.Cause(deleteCtx); errors.Is(cause, ErrLeaseLost) {
+			return true, cause
+		}
+		return true, err
+	}
+}
+
+// CheckRequireMark fails if the options require the mark and resources of the type can't be marked,
+// so the required mark is never silently ignored
+func CheckRequireMark(resourceType string, opts DeleteOptions) error {
+	if opts.RequireMark && !IsMarkable(resourceType) {
+		return fmt.Errorf("%s resources can't be marked for deletion, deleting them requires --require-mark=false", resourceType)
+	}
+	return nil
+}
+
+// Checks quarantine and deletion mark of the resource if required by the options, unmarkable resource types
+// fail while the mark is required
+func isDeletionAllowed(ctx context.Context, cls *clients, resourceType string, resource dto.Resource, opts DeleteOptions) (bool, error) {
+	logger := log.FromContext(ctx)
+	if err := CheckRequireMark(resourceType, opts); err != nil {
+		return false, err
+	}
+	if opts.RequireMark {
+		deleteAfter, ok, err := markTime(ctx, cls, resourceType, resource)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			logger.Info("resource is not marked for deletion, skipping")
+			return false, nil
+		}
+		if time.Now().Before(deleteAfter) {
+			logger.Infof("resource is marked for deletion after %s, skipping", deleteAfter.Format("2006-01-02"))
+			return false, nil
+		}
+	}
+	if opts.QuarantinePeriod > 0 {
+		quarantined, ok, err := quarantineTime(ctx, cls, resourceType, resource)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			logger.Info("resource is not quarantined, skipping")
+			return false, nil
+		}
+		if time.Since(quarantined) < opts.QuarantinePeriod {
+			logger.Infof("resource is quarantined since %s, skipping", quarantined.Format(time.RFC3339))
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Runs the action for each resource in parallel
+func forEachResource(ctx context.Context, resourceType string, accounts []dto.Account, action func(ctx context.Context, cls *clients, resource dto.Resource) error) error {
 	wg := multierror.Group{}
 	for _, account := range accounts {
 		for _, resource := range account.Resources {
 			key := clientKey(account.Name, resource.Location)
 			cls := clientsMap[key]
-			clusterName := resource.Name
 			wg.Go(func() error {
-				ctx, logger := log.UpdateContext(ctx, "account:region", key, "eks", clusterName)
-				logger.Info("finding cluster and vpc")
-				cluster, err := listEKS(ctx, cls.EKS, clusterName)
-				if err != nil {
-					if errors.As(err, &eksNotFoundErr) {
-						logger.Info("cluster not found, probably it was deleted previously")
-						return nil
-					}
-					return err
-				}
-				vpcID := *cluster.ResourcesVpcConfig.VpcId
-				ctx, logger = log.UpdateContext(ctx, "vpc", vpcID)
-				for try := 1; try <= tries; try++ {
-					if try > 1 {
-						logger.Warnf("delete failed, will retry after sleep: %s", err.Error())
-						time.Sleep(retryInterval)
-					}
-					logger.Infof("starting delete process [attempt: %d]", try)
-					if err = deleteVpcAndEks(ctx, cls, vpcID, clusterName); err == nil {
-						break
-					}
-				}
-				return err
+				ctx, _ := log.UpdateContext(ctx, "account:region", key, resourceType, resource.Name)
+				return action(ctx, cls, resource)
 			})
 		}
 	}
 	return wg.Wait().ErrorOrNil()
 }
 
-func deleteVpcAndEks(ctx context.Context, clients *clients, vpcID, clusterName string) error {
+func deleteEKSCluster(ctx context.Context, cls *clients, resource dto.Resource, opts DeleteOptions) error {
+	clusterName := resource.Name
+	logger := log.FromContext(ctx)
+	logger.Info("finding cluster and vpc")
+	cluster, err := listEKS(ctx, cls.EKS, clusterName)
+	if err != nil {
+		if errors.As(err, &eksNotFoundErr) {
+			logger.Info("cluster not found, probably it was deleted previously")
+			return nil
+		}
+		return err
+	}
+	vpcID := *cluster.ResourcesVpcConfig.VpcId
+	keyArns := make([]string, 0)
+	for _, encryption := range cluster.EncryptionConfig {
+		if encryption.Provider != nil && encryption.Provider.KeyArn != nil {
+			keyArns = append(keyArns, *encryption.Provider.KeyArn)
+		}
+	}
+	ctx, logger = log.UpdateContext(ctx, "vpc", vpcID)
+	if opts.DrainKubernetes {
+		logger.Info("draining kubernetes objects")
+		if err := drainKubernetes(ctx, cls, cluster, opts.DrainTimeout); err != nil {
+			logger.Warnf("kubernetes drain failed, continuing with cluster deletion: %s", err.Error())
+		}
+	}
+	return withRetries(ctx, opts, func() error {
+		return deleteVpcAndEks(ctx, cls, vpcID, clusterName, keyArns, opts)
+	})
+}
+
+func withRetries(ctx context.Context, opts DeleteOptions, deleteFn func() error) error {
+	logger := log.FromContext(ctx)
+	var err error
+	for try := 1; try <= opts.Tries; try++ {
+		if try > 1 {
+			// the deletion is stopped, e.g. the lease is lost
+			if ctx.Err() != nil {
+				return err
+			}
+			logger.Warnf("delete failed, will retry after sleep: %s", err.Error())
+			time.Sleep(opts.RetryInterval)
+		}
+		logger.Infof("starting delete process [attempt: %d]", try)
+		if err = deleteFn(); err == nil {
+			break
+		}
+	}
+	return err
+}
+
+func deleteVpcAndEks(ctx context.Context, clients *clients, vpcID, clusterName string, keyArns []string, opts DeleteOptions) error {
 	logger := log.FromContext(ctx)
 	var errs error
 
@@ -57,6 +238,103 @@ func deleteVpcAndEks(ctx context.Context, clients *clients, vpcID, clusterName s
 		errs = multierr.Append(errs, err)
 	}
 
+	if err := deleteVpcDependencies(ctx, clients, vpcID, clusterName); err != nil {
+		errs = multierr.Append(errs, err)
+	}
+
+	logger.Info("deleting cluster node groups")
+	if err := deleteClusterNodeGroups(ctx, clients.EKS, clusterName); err != nil {
+		errs = multierr.Append(errs, err)
+	}
+
+	if err := deleteClusterLaunchResources(ctx, clients, clusterName); err != nil {
+		errs = multierr.Append(errs, err)
+	}
+
+	if opts.PrivateZones {
+		logger.Info("deleting private hosted zones")
+		if err := deletePrivateHostedZones(ctx, clients.Route53, clients.Region, vpcID, clusterName); err != nil {
+			errs = multierr.Append(errs, err)
+		}
+	}
+
+	logger.Info("deleting vpc")
+	if err := deleteVpc(ctx, clients.EC2, vpcID); err != nil {
+		errs = multierr.Append(errs, err)
+	}
+
+	logger.Info("deleting cloud formation")
+	if _, err := listCloudFormationStacks(ctx, clients.CF, clusterName); err != nil {
+		return multierr.Append(errs, err)
+	}
+	if err := deleteCloudFormation(ctx, clients.CF, clusterName); err != nil {
+		errs = multierr.Append(errs, err)
+	}
+
+	if err := deleteClusterRegionalResources(ctx, clients, clusterName, keyArns, opts); err != nil {
+		errs = multierr.Append(errs, err)
+	}
+	return errs
+}
+
+// Deletes opt-in cluster resources that don't belong to the VPC: CloudWatch log groups and KMS keys
+func deleteClusterRegionalResources(ctx context.Context, clients *clients, clusterName string, keyArns []string, opts DeleteOptions) error {
+	logger := log.FromContext(ctx)
+	var errs error
+
+	if opts.LogGroups {
+		logger.Info("listing log groups")
+		logGroups, err := listClusterLogGroups(ctx, clients.Logs, clusterName)
+		if err != nil {
+			return err
+		}
+		logger.Infof("deleting log groups: %d", len(logGroups))
+		if err := deleteLogGroups(ctx, clients.Logs, logGroups); err != nil {
+			errs = multierr.Append(errs, err)
+		}
+	}
+
+	if opts.KMSKeys && len(keyArns) > 0 {
+		logger.Infof("scheduling kms keys deletion: %d", len(keyArns))
+		if err := scheduleKeysDeletion(ctx, clients, clusterName, keyArns, opts.KMSPendingWindow); err != nil {
+			errs = multierr.Append(errs, err)
+		}
+	}
+	return errs
+}
+
+// Deletes launch templates and key pairs tagged for the cluster
+func deleteClusterLaunchResources(ctx context.Context, clients *clients, clusterName string) error {
+	logger := log.FromContext(ctx)
+	var errs error
+
+	logger.Info("listing launch templates")
+	launchTemplates, err := listClusterLaunchTemplates(ctx, clients.EC2, clusterName)
+	if err != nil {
+		return err
+	}
+	logger.Infof("deleting launch templates: %d", len(launchTemplates))
+	if err := deleteLaunchTemplates(ctx, clients.EC2, launchTemplates); err != nil {
+		errs = multierr.Append(errs, err)
+	}
+
+	logger.Info("listing key pairs")
+	keyPairs, err := listClusterKeyPairs(ctx, clients.EC2, clusterName)
+	if err != nil {
+		return multierr.Append(errs, err)
+	}
+	logger.Infof("deleting key pairs: %d", len(keyPairs))
+	if err := deleteKeyPairs(ctx, clients.EC2, keyPairs); err != nil {
+		errs = multierr.Append(errs, err)
+	}
+	return errs
+}
+
+// Deletes everything inside the VPC that blocks the VPC deletion, including cluster autoscaling groups and elastic IPs
+func deleteVpcDependencies(ctx context.Context, clients *clients, vpcID, clusterName string) error {
+	logger := log.FromContext(ctx)
+	var errs error
+
 	logger.Info("listing vpc peering connections")
 	connections, err := listVpcPeeringConnections(ctx, clients.EC2, vpcID)
 	if err != nil {
@@ -99,6 +377,16 @@ func deleteVpcAndEks(ctx context.Context, clients *clients, vpcID, clusterName s
 		errs = multierr.Append(errs, err)
 	}
 
+	logger.Info("listing karpenter instances")
+	karpenterReservations, err := listKarpenterReservations(ctx, clients.EC2, clusterName)
+	if err != nil {
+		return multierr.Append(errs, err)
+	}
+	logger.Infof("deleting karpenter instances reservation: %d", len(karpenterReservations))
+	if err := terminateInstancesInReservations(ctx, clients.EC2, karpenterReservations); err != nil {
+		errs = multierr.Append(errs, err)
+	}
+
 	logger.Info("listing reservation")
 	reservations, err := listReservations(ctx, clients.EC2, vpcID)
 	if err != nil {
@@ -119,23 +407,27 @@ func deleteVpcAndEks(ctx context.Context, clients *clients, vpcID, clusterName s
 		errs = multierr.Append(errs, err)
 	}
 
-	logger.Info("listing elastic ips")
-	ips, err := listElasticIps(ctx, clients.EC2, clusterName)
+	logger.Info("listing nat gateways")
+	nats, err := listNatGateways(ctx, clients.EC2, vpcID)
 	if err != nil {
 		return multierr.Append(errs, err)
 	}
-	logger.Infof("deleting elastic ips: %d", len(ips))
-	if err := releaseElasticIps(ctx, clients.EC2, ips); err != nil {
+	logger.Infof("deleting nat gateways: %d", len(nats))
+	if err := deleteNatGateways(ctx, clients.EC2, nats); err != nil {
 		errs = multierr.Append(errs, err)
 	}
 
-	logger.Info("listing nat gateways")
-	nats, err := listNatGateways(ctx, clients.EC2, vpcID)
+	logger.Info("listing elastic ips")
+	vpcInterfaces, err := listNetworkInterfaces(ctx, clients.EC2, vpcID)
 	if err != nil {
 		return multierr.Append(errs, err)
 	}
-	logger.Infof("deleting nat gateways: %d", len(nats))
-	if err := deleteNatGateways(ctx, clients.EC2, nats); err != nil {
+	ips, err := listElasticIps(ctx, clients.EC2, clusterName, nats, vpcInterfaces)
+	if err != nil {
+		return multierr.Append(errs, err)
+	}
+	logger.Infof("deleting elastic ips: %d", len(ips))
+	if err := releaseElasticIps(ctx, clients.EC2, ips); err != nil {
 		errs = multierr.Append(errs, err)
 	}
 
@@ -198,23 +490,5 @@ func deleteVpcAndEks(ctx context.Context, clients *clients, vpcID, clusterName s
 	if err := deleteRouteTables(ctx, clients.EC2, vpcID, routes); err != nil {
 		errs = multierr.Append(errs, err)
 	}
-
-	logger.Info("deleting cluster node groups")
-	if err := deleteClusterNodeGroups(ctx, clients.EKS, clusterName); err != nil {
-		errs = multierr.Append(errs, err)
-	}
-
-	logger.Info("deleting vpc")
-	if err := deleteVpc(ctx, clients.EC2, vpcID); err != nil {
-		errs = multierr.Append(errs, err)
-	}
-
-	logger.Info("deleting cloud formation")
-	if _, err := listCloudFormationStacks(ctx, clients.CF, clusterName); err != nil {
-		return multierr.Append(errs, err)
-	}
-	if err := deleteCloudFormation(ctx, clients.CF, clusterName); err != nil {
-		errs = multierr.Append(errs, err)
-	}
 	return errs
 }
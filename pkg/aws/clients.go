@@ -6,22 +6,35 @@ import (
 
 	"c7n-helper/pkg/dto"
 	"c7n-helper/pkg/log"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/autoscaling"
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/eks"
 	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancing"
 	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 )
 
 type clients struct {
-	ASG   *autoscaling.Client
-	EC2   *ec2.Client
-	ELB   *elasticloadbalancing.Client
-	ELBv2 *elasticloadbalancingv2.Client
-	EKS   *eks.Client
-	CF    *cloudformation.Client
+	Account string
+	Region  string
+	ASG     *autoscaling.Client
+	EC2     *ec2.Client
+	ELB     *elasticloadbalancing.Client
+	ELBv2   *elasticloadbalancingv2.Client
+	EKS     *eks.Client
+	CF      *cloudformation.Client
+	Logs    *cloudwatchlogs.Client
+	KMS     *kms.Client
+	Route53 *route53.Client
+	S3      *s3.Client
+	STS     *sts.Client
 }
 
 var clientsMap = map[string]*clients{}
@@ -29,27 +42,52 @@ var clientsMap = map[string]*clients{}
 func InitClientsMap(ctx context.Context, accounts []dto.Account) error {
 	for _, account := range accounts {
 		for _, resource := range account.Resources {
-			key := clientKey(account.Name, resource.Location)
-			if _, ok := clientsMap[key]; !ok {
-				log.FromContext(ctx).Infof("initializing aws clients for: %s", key)
-				cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(resource.Location))
-				if err != nil {
-					return err
-				}
-				clientsMap[key] = &clients{
-					ASG:   autoscaling.NewFromConfig(cfg),
-					CF:    cloudformation.NewFromConfig(cfg),
-					EC2:   ec2.NewFromConfig(cfg),
-					ELB:   elasticloadbalancing.NewFromConfig(cfg),
-					ELBv2: elasticloadbalancingv2.NewFromConfig(cfg),
-					EKS:   eks.NewFromConfig(cfg),
-				}
+			if _, err := initClients(ctx, account.Name, resource.Location); err != nil {
+				return err
 			}
 		}
 	}
 	return nil
 }
 
+func initClients(ctx context.Context, account, region string) (*clients, error) {
+	key := clientKey(account, region)
+	if cls, ok := clientsMap[key]; ok {
+		return cls, nil
+	}
+	log.FromContext(ctx).Infof("initializing aws clients for: %s", key)
+	cfg, err := loadConfig(ctx, account, region)
+	if err != nil {
+		return nil, err
+	}
+	cls := &clients{
+		Account: account,
+		Region:  region,
+		ASG:     autoscaling.NewFromConfig(cfg),
+		CF:      cloudformation.NewFromConfig(cfg),
+		EC2:     ec2.NewFromConfig(cfg),
+		ELB:     elasticloadbalancing.NewFromConfig(cfg),
+		ELBv2:   elasticloadbalancingv2.NewFromConfig(cfg),
+		EKS:     eks.NewFromConfig(cfg),
+		Logs:    cloudwatchlogs.NewFromConfig(cfg),
+		KMS:     kms.NewFromConfig(cfg),
+		Route53: route53.NewFromConfig(cfg),
+		S3:      s3.NewFromConfig(cfg),
+		STS:     sts.NewFromConfig(cfg),
+	}
+	clientsMap[key] = cls
+	return cls, nil
+}
+
+// Uses AWS shared config profile credentials if account name matches the profile name, otherwise default credentials
+func loadConfig(ctx context.Context, account, region string) (aws.Config, error) {
+	opts := []func(*config.LoadOptions) error{config.WithRegion(region)}
+	if _, err := config.LoadSharedConfigProfile(ctx, account); err == nil {
+		opts = append(opts, config.WithSharedConfigProfile(account))
+	}
+	return config.LoadDefaultConfig(ctx, opts...)
+}
+
 func clientKey(account, region string) string {
 	return fmt.Sprintf("%s:%s", account, region)
 }
@@ -2,52 +2,51 @@ package aws
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"strings"
+	"io"
 	"time"
 
 	"c7n-helper/pkg/date"
 	"c7n-helper/pkg/dto"
+	"c7n-helper/pkg/expiry"
+	"c7n-helper/pkg/jsonstream"
+	"c7n-helper/pkg/tagschema"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 )
 
-func ParseEC2(region string, content []byte) ([]dto.Resource, error) {
-	var vms []struct {
-		InstanceId   string     `json:"InstanceId"`
-		LaunchTime   time.Time  `json:"LaunchTime"`
-		InstanceType string     `json:"InstanceType"`
-		Tags         []keyValue `json:"Tags"`
-	}
-	if err := json.Unmarshal(content, &vms); err != nil {
-		return nil, err
-	}
-	result := make([]dto.Resource, 0, len(vms))
-	for _, vm := range vms {
-		owner, expiry, name := "", "", ""
-		for _, tag := range vm.Tags {
-			switch strings.ToLower(tag.Key) {
-			case "owner":
-				owner = tag.Value
-			case "expiry":
-				expiry = tag.Value
-			case "name":
-				name = tag.Value
-			}
-		}
+type ec2Instance struct {
+	InstanceId   string     `json:"InstanceId"`
+	LaunchTime   time.Time  `json:"LaunchTime"`
+	InstanceType string     `json:"InstanceType"`
+	Tags         []keyValue `json:"Tags"`
+}
+
+func ParseEC2(region string, r io.Reader, schema tagschema.Config) ([]dto.Resource, error) {
+	result := make([]dto.Resource, 0)
+	err := jsonstream.DecodeArray(r, func(vm ec2Instance) error {
+		tags := tagMap(vm.Tags)
+		values := schema.AWS.Resolve(tags)
+		expiresAt, expiryStatus := expiry.Resolve(values.Expiry, vm.LaunchTime, time.Now())
+		name := values.Name
 		if name == "" {
 			name = fmt.Sprintf("[noname] id: %s", vm.InstanceId)
 		}
 		result = append(result, dto.Resource{
-			Name:     fmt.Sprintf("%s [%s]", name, vm.InstanceType),
-			Location: region,
-			Owner:    owner,
-			Created:  vm.LaunchTime,
-			Expiry:   date.ParseOrDefault(expiry, time.Now()),
+			ID:           vm.InstanceId,
+			Name:         fmt.Sprintf("%s [%s]", name, vm.InstanceType),
+			Location:     region,
+			Owner:        values.Owner,
+			Team:         values.Team,
+			Created:      vm.LaunchTime,
+			Expiry:       expiresAt,
+			ExpiryStatus: expiryStatus,
+			DeleteAfter:  date.ParseOrDefault(tags[deleteAfterTag], time.Time{}),
+			Sources:      values.Sources,
 		})
-	}
-	return result, nil
+		return nil
+	})
+	return result, err
 }
 
 func listReservations(ctx context.Context, client *ec2.Client, vpcId string) ([]types.Reservation, error) {
@@ -68,6 +67,38 @@ func listReservations(ctx context.Context, client *ec2.Client, vpcId string) ([]
 	}
 }
 
+// Returns deduplicated reservations matching any of the filter sets
+func describeReservations(ctx context.Context, client *ec2.Client, filterSets [][]types.Filter) ([]types.Reservation, error) {
+	var reservations []types.Reservation
+	found := make(map[string]struct{})
+	for _, filters := range filterSets {
+		input := ec2.DescribeInstancesInput{
+			Filters: filters,
+		}
+		for {
+			output, err := client.DescribeInstances(ctx, &input)
+			if err != nil {
+				return nil, err
+			}
+			for _, reservation := range output.Reservations {
+				if reservation.ReservationId == nil {
+					continue
+				}
+				if _, ok := found[*reservation.ReservationId]; ok {
+					continue
+				}
+				found[*reservation.ReservationId] = struct{}{}
+				reservations = append(reservations, reservation)
+			}
+			if output.NextToken == nil {
+				break
+			}
+			input.NextToken = output.NextToken
+		}
+	}
+	return reservations, nil
+}
+
 func terminateInstancesInReservations(ctx context.Context, client *ec2.Client, reservations []types.Reservation) error {
 	// Find all non-terminated Instances.
 	var nonTerminatedInstanceIds []string
@@ -2,12 +2,15 @@ package aws
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
+	"io"
 	"time"
 
 	"c7n-helper/pkg/date"
 	"c7n-helper/pkg/dto"
+	"c7n-helper/pkg/expiry"
+	"c7n-helper/pkg/jsonstream"
+	"c7n-helper/pkg/tagschema"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/eks"
 	"github.com/aws/aws-sdk-go-v2/service/eks/types"
@@ -15,33 +18,33 @@ import (
 
 var eksNotFoundErr *types.ResourceNotFoundException
 
-type tags struct {
-	Owner  string `json:"owner"`
-	Expiry string `json:"expiry"`
+type eksCluster struct {
+	Name      string            `json:"name"`
+	CreatedAt time.Time         `json:"createdAt"`
+	Tags      map[string]string `json:"tags"`
+	// the below field is required to avoid conflicts between `tags` and `Tags` because JSON parser is case-insensitive
+	Unused []interface{} `json:"Tags"`
 }
 
-func ParseEKS(region string, content []byte) ([]dto.Resource, error) {
-	var clusters []struct {
-		Name      string    `json:"name"`
-		CreatedAt time.Time `json:"createdAt"`
-		Tags      tags      `json:"tags"`
-		// the below field is required to avoid conflicts between `tags` and `Tags` because JSON parser is case-insensitive
-		Unused []interface{} `json:"Tags"`
-	}
-	if err := json.Unmarshal(content, &clusters); err != nil {
-		return nil, err
-	}
-	result := make([]dto.Resource, 0, len(clusters))
-	for _, cluster := range clusters {
+func ParseEKS(region string, r io.Reader, schema tagschema.Config) ([]dto.Resource, error) {
+	result := make([]dto.Resource, 0)
+	err := jsonstream.DecodeArray(r, func(cluster eksCluster) error {
+		values := schema.AWS.Resolve(cluster.Tags)
+		expiresAt, expiryStatus := expiry.Resolve(values.Expiry, cluster.CreatedAt, time.Now())
 		result = append(result, dto.Resource{
-			Name:     cluster.Name,
-			Location: region,
-			Owner:    cluster.Tags.Owner,
-			Created:  cluster.CreatedAt,
-			Expiry:   date.ParseOrDefault(cluster.Tags.Expiry, time.Now()),
+			Name:         cluster.Name,
+			Location:     region,
+			Owner:        values.Owner,
+			Team:         values.Team,
+			Created:      cluster.CreatedAt,
+			Expiry:       expiresAt,
+			ExpiryStatus: expiryStatus,
+			DeleteAfter:  date.ParseOrDefault(cluster.Tags[deleteAfterTag], time.Time{}),
+			Sources:      values.Sources,
 		})
-	}
-	return result, nil
+		return nil
+	})
+	return result, err
 }
 
 func listEKS(ctx context.Context, client *eks.Client, clusterName string) (*types.Cluster, error) {
@@ -2,35 +2,80 @@ package aws
 
 import (
 	"context"
+	"fmt"
 
+	"c7n-helper/pkg/log"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"go.uber.org/multierr"
 )
 
-func releaseElasticIps(ctx context.Context, client *ec2.Client, addresses []types.Address) (errs error) {
-	for _, address := range addresses {
+type elasticIp struct {
+	address types.Address
+	// NAT gateway addresses can't be disassociated, they are released after NAT gateway deletion
+	natGatewayId string
+	reason       string
+}
+
+func releaseElasticIps(ctx context.Context, client *ec2.Client, ips []elasticIp) (errs error) {
+	for _, ip := range ips {
+		if ip.address.AllocationId == nil {
+			continue
+		}
+		if ip.address.AssociationId != nil && ip.natGatewayId == "" {
+			_, err := client.DisassociateAddress(ctx, &ec2.DisassociateAddressInput{
+				AssociationId: ip.address.AssociationId,
+			})
+			errs = multierr.Append(errs, err)
+			if err != nil {
+				continue
+			}
+		}
 		_, err := client.ReleaseAddress(ctx, &ec2.ReleaseAddressInput{
-			AllocationId: address.AllocationId,
+			AllocationId: ip.address.AllocationId,
 		})
 		errs = multierr.Append(errs, err)
 	}
 	return
 }
 
-func listElasticIps(ctx context.Context, client *ec2.Client, clusterName string) ([]types.Address, error) {
-	filters := []types.Filter{
-		{
-			Name:   aws.String("tag:Name"),
-			Values: []string{clusterName + "*"},
-		},
+// Finds elastic IPs allocated to the VPC NAT gateways, associated with the VPC network interfaces or tagged for the cluster
+func listElasticIps(ctx context.Context, client *ec2.Client, clusterName string, natGateways []types.NatGateway, networkInterfaces []types.NetworkInterface) ([]elasticIp, error) {
+	natAllocations := make(map[string]string)
+	for _, natGateway := range natGateways {
+		for _, address := range natGateway.NatGatewayAddresses {
+			if address.AllocationId != nil {
+				natAllocations[*address.AllocationId] = aws.ToString(natGateway.NatGatewayId)
+			}
+		}
 	}
-	output, err := client.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{
-		Filters: filters,
-	})
+	vpcInterfaces := make(map[string]struct{})
+	for _, networkInterface := range networkInterfaces {
+		if networkInterface.NetworkInterfaceId != nil {
+			vpcInterfaces[*networkInterface.NetworkInterfaceId] = struct{}{}
+		}
+	}
+	output, err := client.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{})
 	if err != nil {
 		return nil, err
 	}
-	return output.Addresses, nil
+	logger := log.FromContext(ctx)
+	ips := make([]elasticIp, 0)
+	for _, address := range output.Addresses {
+		ip := elasticIp{address: address}
+		if natGatewayId, ok := natAllocations[aws.ToString(address.AllocationId)]; ok {
+			ip.natGatewayId = natGatewayId
+			ip.reason = fmt.Sprintf("allocated to vpc nat gateway %s", natGatewayId)
+		} else if _, ok := vpcInterfaces[aws.ToString(address.NetworkInterfaceId)]; ok {
+			ip.reason = fmt.Sprintf("associated with vpc network interface %s", *address.NetworkInterfaceId)
+		} else if tag, ok := clusterTag(address.Tags, clusterName); ok {
+			ip.reason = fmt.Sprintf("tagged with %s=%s", aws.ToString(tag.Key), aws.ToString(tag.Value))
+		} else {
+			continue
+		}
+		logger.Infof("elastic ip %s [%s]: %s", aws.ToString(address.PublicIp), aws.ToString(address.AllocationId), ip.reason)
+		ips = append(ips, ip)
+	}
+	return ips, nil
 }
@@ -0,0 +1,59 @@
+package aws
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// TaggedClusterNames returns EKS cluster names found in the tags
+func TaggedClusterNames(tags []keyValue) []string {
+	clusters := newTaggedClusters()
+	for _, tag := range tags {
+		clusters.add(tag.Key, tag.Value)
+	}
+	return clusters.eksClusterNames()
+}
+
+type KeyValue = keyValue
+
+var UserBucketTags = userBucketTags
+
+// FakeLease is the lease tag of one resource
+type FakeLease struct {
+	mu    sync.Mutex
+	value string
+	err   error
+}
+
+func NewFakeLease(runID string, expiry time.Time) *FakeLease {
+	return &FakeLease{value: formatLease(runID, expiry)}
+}
+
+func (l *FakeLease) Set(value string, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.value, l.err = value, err
+}
+
+func (l *FakeLease) Value() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.value
+}
+
+// RenewLease renews the fake lease until the context is done or the lease is lost
+func RenewLease(ctx context.Context, lease *FakeLease, runID string, duration time.Duration) error {
+	target := &leaseTarget{
+		get: func(context.Context) ([]string, error) {
+			lease.mu.Lock()
+			defer lease.mu.Unlock()
+			return []string{lease.value}, lease.err
+		},
+		set: func(_ context.Context, value string) error {
+			lease.Set(value, nil)
+			return nil
+		},
+	}
+	return renewLease(ctx, target, lease.Value(), DeleteOptions{RunID: runID, LeaseDuration: duration})
+}
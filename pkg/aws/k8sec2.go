@@ -0,0 +1,173 @@
+package aws
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"c7n-helper/pkg/dto"
+	"c7n-helper/pkg/expiry"
+	"c7n-helper/pkg/jsonstream"
+	"c7n-helper/pkg/log"
+	"c7n-helper/pkg/tagschema"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"go.uber.org/multierr"
+)
+
+// ParseK8sEC2 groups C7N EC2 instance or VPC report entries by self-managed Kubernetes cluster tag
+func ParseK8sEC2(region string, r io.Reader, schema tagschema.Config) ([]dto.Resource, error) {
+	type k8sItem struct {
+		LaunchTime time.Time  `json:"LaunchTime"`
+		Tags       []keyValue `json:"Tags"`
+	}
+	clusters := make(map[string]int)
+	expiries := make(map[string]string)
+	result := make([]dto.Resource, 0)
+	err := jsonstream.DecodeArray(r, func(item k8sItem) error {
+		name := kubernetesClusterName(item.Tags)
+		if name == "" {
+			return nil
+		}
+		i, ok := clusters[name]
+		if !ok {
+			result = append(result, dto.Resource{Name: name, Location: region, Sources: make(map[string]string)})
+			i = len(result) - 1
+			clusters[name] = i
+		}
+		cluster := &result[i]
+		if !item.LaunchTime.IsZero() && (cluster.Created.IsZero() || item.LaunchTime.Before(cluster.Created)) {
+			cluster.Created = item.LaunchTime
+		}
+		// the first found value of each field is used
+		values := schema.AWS.Resolve(tagMap(item.Tags))
+		if cluster.Owner == "" && values.Owner != "" {
+			cluster.Owner = values.Owner
+			cluster.Sources[tagschema.Owner] = values.Sources[tagschema.Owner]
+		}
+		if cluster.Team == "" && values.Team != "" {
+			cluster.Team = values.Team
+			cluster.Sources[tagschema.Team] = values.Sources[tagschema.Team]
+		}
+		if expiries[name] == "" && values.Expiry != "" {
+			expiries[name] = values.Expiry
+			cluster.Sources[tagschema.Expiry] = values.Sources[tagschema.Expiry]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range result {
+		result[i].Expiry, result[i].ExpiryStatus = expiry.Resolve(expiries[result[i].Name], result[i].Created, time.Now())
+	}
+	return result, nil
+}
+
+func deleteK8sEC2Cluster(ctx context.Context, cls *clients, resource dto.Resource, opts DeleteOptions) error {
+	clusterName := resource.Name
+	logger := log.FromContext(ctx)
+	logger.Info("finding cluster vpc")
+	vpcIDs, err := listClusterVpcs(ctx, cls.EC2, clusterName)
+	if err != nil {
+		return err
+	}
+	if len(vpcIDs) == 0 {
+		logger.Info("cluster vpc not found, only tagged instances and autoscaling groups will be deleted")
+	}
+	return withRetries(ctx, opts, func() error {
+		return deleteK8sEC2(ctx, cls, vpcIDs, clusterName, opts)
+	})
+}
+
+func deleteK8sEC2(ctx context.Context, clients *clients, vpcIDs []string, clusterName string, opts DeleteOptions) error {
+	logger := log.FromContext(ctx)
+	var errs error
+
+	logger.Info("listing cluster autoscaling groups")
+	scalingGroups, err := listAutoScalingGroups(ctx, clients.ASG, clusterName)
+	if err != nil {
+		return err
+	}
+	logger.Infof("deleting cluster autoscaling groups: %d", len(scalingGroups))
+	if err := deleteAutoScalingGroups(ctx, clients.ASG, clients.EC2, scalingGroups); err != nil {
+		errs = multierr.Append(errs, err)
+	}
+
+	logger.Info("listing cluster instances")
+	reservations, err := listClusterReservations(ctx, clients.EC2, clusterName)
+	if err != nil {
+		return multierr.Append(errs, err)
+	}
+	logger.Infof("deleting cluster instances reservation: %d", len(reservations))
+	if err := terminateInstancesInReservations(ctx, clients.EC2, reservations); err != nil {
+		errs = multierr.Append(errs, err)
+	}
+
+	if err := deleteClusterLaunchResources(ctx, clients, clusterName); err != nil {
+		errs = multierr.Append(errs, err)
+	}
+
+	for _, vpcID := range vpcIDs {
+		ctx, logger := log.UpdateContext(ctx, "vpc", vpcID)
+		if err := deleteVpcDependencies(ctx, clients, vpcID, clusterName); err != nil {
+			errs = multierr.Append(errs, err)
+		}
+		if opts.PrivateZones {
+			logger.Info("deleting private hosted zones")
+			if err := deletePrivateHostedZones(ctx, clients.Route53, clients.Region, vpcID, clusterName); err != nil {
+				errs = multierr.Append(errs, err)
+			}
+		}
+		logger.Info("deleting vpc")
+		if err := deleteVpc(ctx, clients.EC2, vpcID); err != nil {
+			errs = multierr.Append(errs, err)
+		}
+	}
+	return errs
+}
+
+// Returns VPCs created for the cluster, VPCs tagged as `shared` are skipped
+func listClusterVpcs(ctx context.Context, client *ec2.Client, clusterName string) ([]string, error) {
+	vpcIDs := make([]string, 0)
+	found := make(map[string]struct{})
+	for _, filters := range ec2ClusterFilters(clusterName) {
+		input := ec2.DescribeVpcsInput{
+			Filters: filters,
+		}
+		for {
+			output, err := client.DescribeVpcs(ctx, &input)
+			if err != nil {
+				return nil, err
+			}
+			for _, vpc := range output.Vpcs {
+				if vpc.VpcId == nil || isSharedWithCluster(vpc.Tags, clusterName) {
+					continue
+				}
+				if _, ok := found[*vpc.VpcId]; ok {
+					continue
+				}
+				found[*vpc.VpcId] = struct{}{}
+				vpcIDs = append(vpcIDs, *vpc.VpcId)
+			}
+			if output.NextToken == nil {
+				break
+			}
+			input.NextToken = output.NextToken
+		}
+	}
+	return vpcIDs, nil
+}
+
+func listClusterReservations(ctx context.Context, client *ec2.Client, clusterName string) ([]types.Reservation, error) {
+	return describeReservations(ctx, client, ec2ClusterFilters(clusterName))
+}
+
+func isSharedWithCluster(tags []types.Tag, clusterName string) bool {
+	for _, tag := range tags {
+		if tag.Key != nil && *tag.Key == kubernetesClusterTagPrefix+clusterName {
+			return tag.Value != nil && *tag.Value == "shared"
+		}
+	}
+	return false
+}
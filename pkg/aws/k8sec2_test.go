@@ -0,0 +1,30 @@
+package aws_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"c7n-helper/pkg/aws"
+	"c7n-helper/pkg/tagschema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseK8sEC2(t *testing.T) {
+	content := strings.NewReader(`[
+		{"InstanceId": "i-1", "LaunchTime": "2024-05-02T10:00:00Z", "Tags": [{"Key": "kubernetes.io/cluster/kops-1", "Value": "owned"}]},
+		{"InstanceId": "i-2", "LaunchTime": "2024-05-01T10:00:00Z", "Tags": [{"Key": "KubernetesCluster", "Value": "kops-1"}, {"Key": "owner", "Value": "alice"}, {"Key": "expiry", "Value": "2024-06-01"}]},
+		{"InstanceId": "i-3", "LaunchTime": "2024-05-03T10:00:00Z", "Tags": [{"Key": "Name", "Value": "standalone"}]},
+		{"VpcId": "vpc-1", "Tags": [{"Key": "kubernetes.io/cluster/kubeadm-1", "Value": "owned"}]}
+	]`)
+	resources, err := aws.ParseK8sEC2("us-east-1", content, tagschema.Default())
+	assert.NoError(t, err)
+	assert.Len(t, resources, 2)
+	assert.Equal(t, "kops-1", resources[0].Name)
+	assert.Equal(t, "us-east-1", resources[0].Location)
+	assert.Equal(t, "alice", resources[0].Owner)
+	assert.Equal(t, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), resources[0].Created)
+	assert.Equal(t, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), resources[0].Expiry)
+	assert.Equal(t, "kubeadm-1", resources[1].Name)
+	assert.True(t, resources[1].Created.IsZero())
+}
@@ -0,0 +1,35 @@
+package aws
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// Tags set by Karpenter on launched instances (NodeClaims)
+var karpenterInstanceTags = []string{
+	"karpenter.sh/nodepool",
+	"karpenter.sh/nodeclaim",
+	"karpenter.sh/provisioner-name",
+	"karpenter.k8s.aws/ec2nodeclass",
+}
+
+// Karpenter instances don't belong to any autoscaling group, so they are found by Karpenter and cluster tags
+func listKarpenterReservations(ctx context.Context, client *ec2.Client, clusterName string) ([]types.Reservation, error) {
+	filterSets := ec2ClusterFilters(clusterName)
+	for i := range filterSets {
+		filterSets[i] = append(filterSets[i],
+			types.Filter{
+				Name:   aws.String("tag-key"),
+				Values: karpenterInstanceTags,
+			},
+			types.Filter{
+				Name:   aws.String("instance-state-name"),
+				Values: []string{"pending", "running", "stopping", "stopped"},
+			},
+		)
+	}
+	return describeReservations(ctx, client, filterSets)
+}
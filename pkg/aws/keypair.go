@@ -0,0 +1,46 @@
+package aws
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"go.uber.org/multierr"
+)
+
+func deleteKeyPairs(ctx context.Context, client *ec2.Client, keyPairs []types.KeyPairInfo) (errs error) {
+	for _, keyPair := range keyPairs {
+		if keyPair.KeyPairId == nil {
+			continue
+		}
+		_, err := client.DeleteKeyPair(ctx, &ec2.DeleteKeyPairInput{
+			KeyPairId: keyPair.KeyPairId,
+		})
+		errs = multierr.Append(errs, err)
+	}
+	return
+}
+
+func listClusterKeyPairs(ctx context.Context, client *ec2.Client, clusterName string) ([]types.KeyPairInfo, error) {
+	var keyPairs []types.KeyPairInfo
+	found := make(map[string]struct{})
+	for _, filters := range ec2ClusterFilters(clusterName) {
+		output, err := client.DescribeKeyPairs(ctx, &ec2.DescribeKeyPairsInput{
+			Filters: filters,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, keyPair := range output.KeyPairs {
+			if keyPair.KeyPairId == nil {
+				continue
+			}
+			if _, ok := found[*keyPair.KeyPairId]; ok {
+				continue
+			}
+			found[*keyPair.KeyPairId] = struct{}{}
+			keyPairs = append(keyPairs, keyPair)
+		}
+	}
+	return keyPairs, nil
+}
@@ -0,0 +1,123 @@
+package aws
+
+import (
+	"context"
+	"errors"
+
+	"c7n-helper/pkg/log"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"go.uber.org/multierr"
+)
+
+var kmsNotFoundErr *types.NotFoundException
+
+// Schedules deletion of customer managed keys of the cluster, AWS managed keys and keys pending deletion are skipped.
+// Keys used by other EKS clusters of the region or tagged for another cluster are shared and skipped too.
+func scheduleKeysDeletion(ctx context.Context, clients *clients, clusterName string, keyArns []string, pendingWindowDays int32) (errs error) {
+	logger := log.FromContext(ctx)
+	client := clients.KMS
+	sharedKeys, err := otherClustersKeys(ctx, clients.EKS, clusterName)
+	if err != nil {
+		return err
+	}
+	for _, keyArn := range keyArns {
+		output, err := client.DescribeKey(ctx, &kms.DescribeKeyInput{
+			KeyId: aws.String(keyArn),
+		})
+		if errors.As(err, &kmsNotFoundErr) {
+			continue
+		}
+		if err != nil {
+			errs = multierr.Append(errs, err)
+			continue
+		}
+		metadata := output.KeyMetadata
+		if metadata.KeyManager != types.KeyManagerTypeCustomer || metadata.KeyState == types.KeyStatePendingDeletion {
+			continue
+		}
+		if _, ok := sharedKeys[keyArn]; ok {
+			logger.Infof("skipping kms key used by another cluster: %s", keyArn)
+			continue
+		}
+		if _, ok := sharedKeys[aws.ToString(metadata.Arn)]; ok {
+			logger.Infof("skipping kms key used by another cluster: %s", keyArn)
+			continue
+		}
+		owner, err := keyClusterName(ctx, client, metadata.KeyId)
+		if err != nil {
+			errs = multierr.Append(errs, err)
+			continue
+		}
+		if owner != "" && owner != clusterName {
+			logger.Infof("skipping kms key tagged for cluster %s: %s", owner, keyArn)
+			continue
+		}
+		logger.Infof("scheduling kms key deletion in %d days: %s", pendingWindowDays, keyArn)
+		_, err = client.ScheduleKeyDeletion(ctx, &kms.ScheduleKeyDeletionInput{
+			KeyId:               metadata.KeyId,
+			PendingWindowInDays: aws.Int32(pendingWindowDays),
+		})
+		errs = multierr.Append(errs, err)
+	}
+	return
+}
+
+// Returns key ARNs of secrets encryption of the region EKS clusters except the given one
+func otherClustersKeys(ctx context.Context, client *eks.Client, clusterName string) (map[string]struct{}, error) {
+	keys := make(map[string]struct{})
+	input := &eks.ListClustersInput{}
+	for {
+		output, err := client.ListClusters(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range output.Clusters {
+			if name == clusterName {
+				continue
+			}
+			cluster, err := listEKS(ctx, client, name)
+			if errors.As(err, &eksNotFoundErr) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			for _, encryption := range cluster.EncryptionConfig {
+				if encryption.Provider != nil && encryption.Provider.KeyArn != nil {
+					keys[*encryption.Provider.KeyArn] = struct{}{}
+				}
+			}
+		}
+		if output.NextToken == nil {
+			return keys, nil
+		}
+		input.NextToken = output.NextToken
+	}
+}
+
+// Returns cluster name from the key cluster tags, empty if the key is not tagged for a cluster
+func keyClusterName(ctx context.Context, client *kms.Client, keyID *string) (string, error) {
+	input := &kms.ListResourceTagsInput{KeyId: keyID}
+	for {
+		output, err := client.ListResourceTags(ctx, input)
+		if err != nil {
+			return "", err
+		}
+		for _, tag := range output.Tags {
+			key, value := aws.ToString(tag.TagKey), aws.ToString(tag.TagValue)
+			if name := clusterNameFromTag(key, value); name != "" {
+				return name, nil
+			}
+			if _, ok := eksClusterNameTags[key]; ok && value != "" {
+				return value, nil
+			}
+		}
+		if !output.Truncated {
+			return "", nil
+		}
+		input.Marker = output.NextMarker
+	}
+}
@@ -0,0 +1,63 @@
+package aws
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"c7n-helper/pkg/kube"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+const (
+	clusterIDHeader   = "x-k8s-aws-id"
+	eksTokenPrefix    = "k8s-aws-v1."
+	eksTokenExpiresIn = "60"
+)
+
+// Deletes Kubernetes objects owning cloud resources through the cluster API, so in-cluster controllers clean them up
+func drainKubernetes(ctx context.Context, clients *clients, cluster *types.Cluster, timeout time.Duration) error {
+	if cluster.Status != types.ClusterStatusActive {
+		return fmt.Errorf("cluster status is %s", cluster.Status)
+	}
+	if cluster.Endpoint == nil || cluster.CertificateAuthority == nil {
+		return fmt.Errorf("cluster endpoint is not available")
+	}
+	ca, err := base64.StdEncoding.DecodeString(aws.ToString(cluster.CertificateAuthority.Data))
+	if err != nil {
+		return err
+	}
+	token, err := clusterToken(ctx, clients.STS, aws.ToString(cluster.Name))
+	if err != nil {
+		return err
+	}
+	client, err := kube.NewClient(kube.Config{
+		Server:                   *cluster.Endpoint,
+		CertificateAuthorityData: ca,
+		Token:                    token,
+	})
+	if err != nil {
+		return err
+	}
+	return client.Drain(ctx, timeout)
+}
+
+// EKS token is a presigned STS GetCallerIdentity URL with the cluster name header (same as `aws eks get-token`)
+func clusterToken(ctx context.Context, client *sts.Client, clusterName string) (string, error) {
+	presigned, err := sts.NewPresignClient(client).PresignGetCallerIdentity(ctx, &sts.GetCallerIdentityInput{},
+		func(options *sts.PresignOptions) {
+			options.ClientOptions = append(options.ClientOptions, func(o *sts.Options) {
+				o.APIOptions = append(o.APIOptions,
+					smithyhttp.SetHeaderValue(clusterIDHeader, clusterName),
+					smithyhttp.SetHeaderValue("X-Amz-Expires", eksTokenExpiresIn))
+			})
+		})
+	if err != nil {
+		return "", err
+	}
+	return eksTokenPrefix + base64.RawURLEncoding.EncodeToString([]byte(presigned.URL)), nil
+}
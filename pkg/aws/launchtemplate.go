@@ -0,0 +1,53 @@
+package aws
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"go.uber.org/multierr"
+)
+
+func deleteLaunchTemplates(ctx context.Context, client *ec2.Client, launchTemplates []types.LaunchTemplate) (errs error) {
+	for _, launchTemplate := range launchTemplates {
+		if launchTemplate.LaunchTemplateId == nil {
+			continue
+		}
+		_, err := client.DeleteLaunchTemplate(ctx, &ec2.DeleteLaunchTemplateInput{
+			LaunchTemplateId: launchTemplate.LaunchTemplateId,
+		})
+		errs = multierr.Append(errs, err)
+	}
+	return
+}
+
+func listClusterLaunchTemplates(ctx context.Context, client *ec2.Client, clusterName string) ([]types.LaunchTemplate, error) {
+	var launchTemplates []types.LaunchTemplate
+	found := make(map[string]struct{})
+	for _, filters := range ec2ClusterFilters(clusterName) {
+		input := ec2.DescribeLaunchTemplatesInput{
+			Filters: filters,
+		}
+		for {
+			output, err := client.DescribeLaunchTemplates(ctx, &input)
+			if err != nil {
+				return nil, err
+			}
+			for _, launchTemplate := range output.LaunchTemplates {
+				if launchTemplate.LaunchTemplateId == nil {
+					continue
+				}
+				if _, ok := found[*launchTemplate.LaunchTemplateId]; ok {
+					continue
+				}
+				found[*launchTemplate.LaunchTemplateId] = struct{}{}
+				launchTemplates = append(launchTemplates, launchTemplate)
+			}
+			if output.NextToken == nil {
+				break
+			}
+			input.NextToken = output.NextToken
+		}
+	}
+	return launchTemplates, nil
+}
@@ -0,0 +1,282 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"c7n-helper/pkg/dto"
+	"c7n-helper/pkg/log"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/smithy-go"
+)
+
+const (
+	// Lease value: `<run-id> <expiry in RFC3339>`
+	leaseTag = "c7n-helper/lease"
+	// Pause between the lease write and the check that another run did not overwrite it
+	leaseVerifyDelay = time.Second * 5
+)
+
+// ErrLeaseLost stops the deletion of the resource when another run took over the lease or the lease expired
+var ErrLeaseLost = errors.New("lease lost")
+
+// Tagged resources that hold the cleanup lease: EKS cluster or cluster VPCs
+type leaseTarget struct {
+	// Lease tag value of each tagged resource, empty if the resource has no lease
+	get    func(ctx context.Context) ([]string, error)
+	set    func(ctx context.Context, value string) error
+	remove func(ctx context.Context) error
+}
+
+// Takes the resource lease for the run and renews it in background until the returned release function is called.
+// The returned context is cancelled with ErrLeaseLost as the cause if the lease is lost, the deletion must use it.
+// Returns false if the resource is leased by another run.
+// The lease is best-effort: tagging APIs are not conditional writes, so two runs writing the lease at the same time
+// are detected by re-reading the tags after a delay, which narrows the race but doesn't exclude it.
+func takeLease(ctx context.Context, cls *clients, resourceType string, resource dto.Resource, opts DeleteOptions) (context.Context, func(), bool, error) {
+	logger := log.FromContext(ctx)
+	target, err := newLeaseTarget(ctx, cls, resourceType, resource)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if target == nil {
+		// nothing to tag, the resource is already deleted
+		return ctx, func() {}, true, nil
+	}
+	values, err := target.get(ctx)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	for _, value := range values {
+		if holder, expiry, ok := parseLease(value); ok && holder != opts.RunID {
+			if time.Now().Before(expiry) {
+				logger.Infof("resource is leased by run %s until %s, skipping", holder, expiry.Format(time.RFC3339))
+				return nil, nil, false, nil
+			}
+			logger.Infof("taking over lease of run %s expired at %s", holder, expiry.Format(time.RFC3339))
+		}
+	}
+	lease := formatLease(opts.RunID, time.Now().Add(opts.LeaseDuration))
+	if err := target.set(ctx, lease); err != nil {
+		return nil, nil, false, err
+	}
+	// tagging APIs are not conditional, another run could write its lease at the same time
+	time.Sleep(leaseVerifyDelay)
+	if values, err = target.get(ctx); err != nil {
+		return nil, nil, false, err
+	}
+	if err := checkLease(values, lease); err != nil {
+		logger.Infof("%s, skipping", err.Error())
+		return nil, nil, false, nil
+	}
+	logger.Infof("lease is taken until %s", strings.Fields(lease)[1])
+
+	leaseCtx, cancel := context.WithCancelCause(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := renewLease(leaseCtx, target, lease, opts); err != nil {
+			logger.Warnf("stopping deletion: %s", err.Error())
+			cancel(err)
+		}
+	}()
+	release := func() {
+		cancel(nil)
+		<-done
+		// the lost lease belongs to another run now
+		if errors.Is(context.Cause(leaseCtx), ErrLeaseLost) {
+			return
+		}
+		if err := target.remove(ctx); err != nil {
+			logger.Warnf("unable to release lease: %s", err.Error())
+		}
+	}
+	return leaseCtx, release, true, nil
+}
+
+// Renews the lease every third of the lease duration until the context is done. Each renewal first checks
+// that every tagged resource still holds the lease written by the run, returns ErrLeaseLost if another run
+// overwrote or removed it, or if failed renewals let it expire.
+func renewLease(ctx context.Context, target *leaseTarget, lease string, opts DeleteOptions) error {
+	logger := log.FromContext(ctx)
+	ticker := time.NewTicker(opts.LeaseDuration / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		renewed, err := checkAndRenewLease(ctx, target, lease, opts)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err == nil {
+			lease = renewed
+			continue
+		}
+		if errors.Is(err, ErrLeaseLost) {
+			return err
+		}
+		logger.Warnf("unable to renew lease: %s", err.Error())
+		if _, expiry, _ := parseLease(lease); time.Now().After(expiry) {
+			return fmt.Errorf("%w: expired at %s", ErrLeaseLost, expiry.Format(time.RFC3339))
+		}
+	}
+}
+
+// Returns the renewed lease if the resources still hold the current one
+func checkAndRenewLease(ctx context.Context, target *leaseTarget, lease string, opts DeleteOptions) (string, error) {
+	values, err := target.get(ctx)
+	if err != nil {
+		return "", err
+	}
+	if err := checkLease(values, lease); err != nil {
+		return "", err
+	}
+	renewed := formatLease(opts.RunID, time.Now().Add(opts.LeaseDuration))
+	if err := target.set(ctx, renewed); err != nil {
+		return "", err
+	}
+	return renewed, nil
+}
+
+// Fails with ErrLeaseLost unless every tagged resource holds the lease
+func checkLease(values []string, lease string) error {
+	for _, value := range values {
+		if value == lease {
+			continue
+		}
+		if holder, _, ok := parseLease(value); ok {
+			return fmt.Errorf("%w: taken by run %s", ErrLeaseLost, holder)
+		}
+		return fmt.Errorf("%w: lease tag is removed", ErrLeaseLost)
+	}
+	return nil
+}
+
+// Returns nil if the resource is not found
+func newLeaseTarget(ctx context.Context, cls *clients, resourceType string, resource dto.Resource) (*leaseTarget, error) {
+	switch resourceType {
+	case "eks":
+		cluster, err := listEKS(ctx, cls.EKS, resource.Name)
+		if errors.As(err, &eksNotFoundErr) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return eksLeaseTarget(cls.EKS, resource.Name, aws.ToString(cluster.Arn)), nil
+	case "k8s-ec2", "eks-orphan":
+		vpcIDs, err := listClusterVpcs(ctx, cls.EC2, resource.Name)
+		if err != nil || len(vpcIDs) == 0 {
+			return nil, err
+		}
+		return ec2LeaseTarget(cls.EC2, vpcIDs), nil
+	}
+	return nil, fmt.Errorf("lease is not supported for %s", resourceType)
+}
+
+func eksLeaseTarget(client *eks.Client, clusterName, arn string) *leaseTarget {
+	return &leaseTarget{
+		get: func(ctx context.Context) ([]string, error) {
+			cluster, err := listEKS(ctx, client, clusterName)
+			if err != nil {
+				return nil, err
+			}
+			return []string{cluster.Tags[leaseTag]}, nil
+		},
+		set: func(ctx context.Context, value string) error {
+			_, err := client.TagResource(ctx, &eks.TagResourceInput{
+				ResourceArn: aws.String(arn),
+				Tags:        map[string]string{leaseTag: value},
+			})
+			return err
+		},
+		remove: func(ctx context.Context) error {
+			_, err := client.UntagResource(ctx, &eks.UntagResourceInput{
+				ResourceArn: aws.String(arn),
+				TagKeys:     []string{leaseTag},
+			})
+			if errors.As(err, &eksNotFoundErr) {
+				return nil
+			}
+			return err
+		},
+	}
+}
+
+func ec2LeaseTarget(client *ec2.Client, resourceIDs []string) *leaseTarget {
+	return &leaseTarget{
+		get: func(ctx context.Context) ([]string, error) {
+			leases := make(map[string]string, len(resourceIDs))
+			paginator := ec2.NewDescribeTagsPaginator(client, &ec2.DescribeTagsInput{
+				Filters: []ec2types.Filter{
+					{
+						Name:   aws.String("resource-id"),
+						Values: resourceIDs,
+					},
+					{
+						Name:   aws.String("key"),
+						Values: []string{leaseTag},
+					},
+				},
+			})
+			for paginator.HasMorePages() {
+				output, err := paginator.NextPage(ctx)
+				if err != nil {
+					return nil, err
+				}
+				for _, tag := range output.Tags {
+					leases[aws.ToString(tag.ResourceId)] = aws.ToString(tag.Value)
+				}
+			}
+			// resources without the tag have no lease
+			values := make([]string, 0, len(resourceIDs))
+			for _, id := range resourceIDs {
+				values = append(values, leases[id])
+			}
+			return values, nil
+		},
+		set: func(ctx context.Context, value string) error {
+			_, err := client.CreateTags(ctx, &ec2.CreateTagsInput{
+				Resources: resourceIDs,
+				Tags:      []ec2types.Tag{{Key: aws.String(leaseTag), Value: aws.String(value)}},
+			})
+			return err
+		},
+		remove: func(ctx context.Context) error {
+			_, err := client.DeleteTags(ctx, &ec2.DeleteTagsInput{
+				Resources: resourceIDs,
+				Tags:      []ec2types.Tag{{Key: aws.String(leaseTag)}},
+			})
+			// the resources are usually deleted at this point
+			if apiErr := (smithy.APIError)(nil); errors.As(err, &apiErr) && strings.HasSuffix(apiErr.ErrorCode(), ".NotFound") {
+				return nil
+			}
+			return err
+		},
+	}
+}
+
+func formatLease(runID string, expiry time.Time) string {
+	return fmt.Sprintf("%s %s", runID, expiry.UTC().Format(time.RFC3339))
+}
+
+func parseLease(value string) (string, time.Time, bool) {
+	parts := strings.Fields(value)
+	if len(parts) != 2 {
+		return "", time.Time{}, false
+	}
+	expiry, err := time.Parse(time.RFC3339, parts[1])
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return parts[0], expiry, true
+}
@@ -0,0 +1,44 @@
+package aws_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"c7n-helper/pkg/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenewLease(t *testing.T) {
+	lease := aws.NewFakeLease("run-1", time.Now().Add(time.Minute))
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	assert.NoError(t, aws.RenewLease(ctx, lease, "run-1", 300*time.Millisecond))
+	assert.True(t, strings.HasPrefix(lease.Value(), "run-1 "))
+}
+
+func TestRenewLeaseLost(t *testing.T) {
+	lease := aws.NewFakeLease("run-1", time.Now().Add(time.Minute))
+	go func() {
+		time.Sleep(150 * time.Millisecond)
+		// another run overwrites the lease between renewals
+		lease.Set("run-2 "+time.Now().Add(time.Hour).UTC().Format(time.RFC3339), nil)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := aws.RenewLease(ctx, lease, "run-1", 300*time.Millisecond)
+	assert.ErrorIs(t, err, aws.ErrLeaseLost)
+	assert.ErrorContains(t, err, "run-2")
+	// the lease of the other run is kept
+	assert.True(t, strings.HasPrefix(lease.Value(), "run-2 "))
+}
+
+func TestRenewLeaseExpired(t *testing.T) {
+	lease := aws.NewFakeLease("run-1", time.Now().Add(-time.Second))
+	lease.Set(lease.Value(), errors.New("throttled"))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.ErrorIs(t, aws.RenewLease(ctx, lease, "run-1", 300*time.Millisecond), aws.ErrLeaseLost)
+}
@@ -0,0 +1,43 @@
+package aws
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrLimitReached is returned for resources that are not deleted because the deletion limit is reached
+var ErrLimitReached = errors.New("deletion limit reached")
+
+// DeletionLimits counts resources that passed approval, mark, quarantine, window and lease checks,
+// so skipped resources don't count
+type DeletionLimits struct {
+	max, maxPerAccount int
+
+	mu         sync.Mutex
+	total      int
+	perAccount map[string]int
+}
+
+func NewDeletionLimits(max, maxPerAccount int) *DeletionLimits {
+	return &DeletionLimits{max: max, maxPerAccount: maxPerAccount, perAccount: make(map[string]int)}
+}
+
+// Counts the resource deletion in the account, fails if a limit is reached, nil limits are unlimited
+func (l *DeletionLimits) take(account string) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.total >= l.max {
+		return fmt.Errorf("%w: %d resources per run, raise --max-deletions explicitly", ErrLimitReached, l.max)
+	}
+	if l.perAccount[account] >= l.maxPerAccount {
+		return fmt.Errorf("%w: %d resources in account %s, raise --max-deletions-per-account explicitly",
+			ErrLimitReached, l.maxPerAccount, account)
+	}
+	l.total++
+	l.perAccount[account]++
+	return nil
+}
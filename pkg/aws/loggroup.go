@@ -0,0 +1,48 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"go.uber.org/multierr"
+)
+
+var logGroupNotFoundErr *types.ResourceNotFoundException
+
+func deleteLogGroups(ctx context.Context, client *cloudwatchlogs.Client, logGroups []types.LogGroup) (errs error) {
+	for _, logGroup := range logGroups {
+		if logGroup.LogGroupName == nil {
+			continue
+		}
+		_, err := client.DeleteLogGroup(ctx, &cloudwatchlogs.DeleteLogGroupInput{
+			LogGroupName: logGroup.LogGroupName,
+		})
+		if err != nil && !errors.As(err, &logGroupNotFoundErr) {
+			errs = multierr.Append(errs, err)
+		}
+	}
+	return
+}
+
+// EKS control plane logs are written to `/aws/eks/<cluster-name>/cluster` log group
+func listClusterLogGroups(ctx context.Context, client *cloudwatchlogs.Client, clusterName string) ([]types.LogGroup, error) {
+	input := cloudwatchlogs.DescribeLogGroupsInput{
+		LogGroupNamePrefix: aws.String(fmt.Sprintf("/aws/eks/%s/", clusterName)),
+	}
+	var logGroups []types.LogGroup
+	for {
+		output, err := client.DescribeLogGroups(ctx, &input)
+		if err != nil {
+			return nil, err
+		}
+		logGroups = append(logGroups, output.LogGroups...)
+		if output.NextToken == nil {
+			return logGroups, nil
+		}
+		input.NextToken = output.NextToken
+	}
+}
@@ -0,0 +1,144 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"c7n-helper/pkg/date"
+	"c7n-helper/pkg/dto"
+	"c7n-helper/pkg/log"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// Scheduled deletion date in 2006-01-02 format
+const deleteAfterTag = "c7n-helper/delete-after"
+
+type markAction func(ctx context.Context, clients *clients, resource dto.Resource, deleteAfter string) error
+
+var resourceMarkers = map[string]markAction{
+	"eks": markEKSCluster,
+	"ec2": markEC2Instance,
+	"s3":  markS3Bucket,
+}
+
+func IsMarkable(resourceType string) bool {
+	_, ok := resourceMarkers[resourceType]
+	return ok
+}
+
+// MarkResources tags resources with the scheduled deletion date, already marked resources are not changed
+func MarkResources(ctx context.Context, resourceType string, accounts []dto.Account, deleteAfter string) error {
+	mark, ok := resourceMarkers[resourceType]
+	if !ok {
+		return errors.New("unsupported resource type")
+	}
+	return forEachResource(ctx, resourceType, accounts, func(ctx context.Context, cls *clients, resource dto.Resource) error {
+		return mark(ctx, cls, resource, deleteAfter)
+	})
+}
+
+func markEKSCluster(ctx context.Context, clients *clients, resource dto.Resource, deleteAfter string) error {
+	logger := log.FromContext(ctx)
+	cluster, err := listEKS(ctx, clients.EKS, resource.Name)
+	if errors.As(err, &eksNotFoundErr) {
+		logger.Info("cluster not found, skipping")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if value, ok := cluster.Tags[deleteAfterTag]; ok {
+		logger.Infof("already marked for deletion after %s", value)
+		return nil
+	}
+	logger.Infof("marking for deletion after %s", deleteAfter)
+	_, err = clients.EKS.TagResource(ctx, &eks.TagResourceInput{
+		ResourceArn: cluster.Arn,
+		Tags:        map[string]string{deleteAfterTag: deleteAfter},
+	})
+	return err
+}
+
+func markEC2Instance(ctx context.Context, clients *clients, resource dto.Resource, deleteAfter string) error {
+	logger := log.FromContext(ctx)
+	instance, err := describeInstance(ctx, clients.EC2, resource.ID)
+	if err != nil || instance == nil {
+		return err
+	}
+	if value, ok := ec2Tag(instance.Tags, deleteAfterTag); ok {
+		logger.Infof("already marked for deletion after %s", value)
+		return nil
+	}
+	logger.Infof("marking for deletion after %s", deleteAfter)
+	_, err = clients.EC2.CreateTags(ctx, &ec2.CreateTagsInput{
+		Resources: []string{aws.ToString(instance.InstanceId)},
+		Tags:      []ec2types.Tag{{Key: aws.String(deleteAfterTag), Value: aws.String(deleteAfter)}},
+	})
+	return err
+}
+
+func markS3Bucket(ctx context.Context, clients *clients, resource dto.Resource, deleteAfter string) error {
+	logger := log.FromContext(ctx)
+	tagSet, err := bucketTags(ctx, clients.S3, resource.Name)
+	if err != nil {
+		return err
+	}
+	for _, tag := range tagSet {
+		if aws.ToString(tag.Key) == deleteAfterTag {
+			logger.Infof("already marked for deletion after %s", aws.ToString(tag.Value))
+			return nil
+		}
+	}
+	logger.Infof("marking for deletion after %s", deleteAfter)
+	// bucket tagging API replaces the whole tag set
+	tagSet = append(userBucketTags(tagSet), s3types.Tag{Key: aws.String(deleteAfterTag), Value: aws.String(deleteAfter)})
+	_, err = clients.S3.PutBucketTagging(ctx, &s3.PutBucketTaggingInput{
+		Bucket:  aws.String(resource.Name),
+		Tagging: &s3types.Tagging{TagSet: tagSet},
+	})
+	return err
+}
+
+// Returns tags without `aws:` prefixed system tags (e.g. CloudFormation stack tags) rejected by PutBucketTagging
+func userBucketTags(tagSet []s3types.Tag) []s3types.Tag {
+	result := make([]s3types.Tag, 0, len(tagSet))
+	for _, tag := range tagSet {
+		if strings.HasPrefix(aws.ToString(tag.Key), "aws:") {
+			continue
+		}
+		result = append(result, tag)
+	}
+	return result
+}
+
+func bucketTags(ctx context.Context, client *s3.Client, bucket string) ([]s3types.Tag, error) {
+	output, err := client.GetBucketTagging(ctx, &s3.GetBucketTaggingInput{Bucket: aws.String(bucket)})
+	if apiErr := (smithy.APIError)(nil); errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchTagSet" {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return output.TagSet, nil
+}
+
+// Returns resource scheduled deletion date, false if the resource is not marked
+func markTime(ctx context.Context, clients *clients, resourceType string, resource dto.Resource) (time.Time, bool, error) {
+	value, err := resourceTag(ctx, clients, resourceType, resource, deleteAfterTag)
+	if err != nil || value == "" {
+		return time.Time{}, false, err
+	}
+	t := date.ParseOrDefault(value, time.Time{})
+	if t.IsZero() {
+		return time.Time{}, false, errors.New("invalid delete-after tag value: " + value)
+	}
+	return t, true, nil
+}
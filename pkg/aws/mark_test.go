@@ -0,0 +1,27 @@
+package aws_test
+
+import (
+	"testing"
+
+	"c7n-helper/pkg/aws"
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserBucketTags(t *testing.T) {
+	tags := aws.UserBucketTags([]s3types.Tag{
+		{Key: awssdk.String("aws:cloudformation:stack-name"), Value: awssdk.String("stack")},
+		{Key: awssdk.String("owner"), Value: awssdk.String("alice")},
+		{Key: awssdk.String("aws:cloudformation:logical-id"), Value: awssdk.String("Bucket")},
+	})
+	assert.Len(t, tags, 1)
+	assert.Equal(t, "owner", awssdk.ToString(tags[0].Key))
+}
+
+func TestCheckRequireMark(t *testing.T) {
+	assert.NoError(t, aws.CheckRequireMark("eks", aws.DeleteOptions{RequireMark: true}))
+	assert.ErrorContains(t, aws.CheckRequireMark("k8s-ec2", aws.DeleteOptions{RequireMark: true}), "--require-mark=false")
+	assert.Error(t, aws.CheckRequireMark("eks-orphan", aws.DeleteOptions{RequireMark: true}))
+	assert.NoError(t, aws.CheckRequireMark("k8s-ec2", aws.DeleteOptions{}))
+}
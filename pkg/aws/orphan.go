@@ -0,0 +1,217 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"c7n-helper/pkg/dto"
+	"c7n-helper/pkg/log"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/autoscaling"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"go.uber.org/multierr"
+)
+
+// FindOrphans scans accounts (AWS shared config profiles) and regions for resources tagged for EKS clusters
+// that no longer exist. Clusters named in EKS, eksctl or `kubernetes.io/cluster/<name>` tags are checked with
+// EKS API, clusters tagged with `KubernetesCluster` are self-managed (kops) and skipped.
+// Failed regions are skipped, resources found in other regions are returned with the combined error.
+func FindOrphans(ctx context.Context, accounts, regions []string) ([]dto.Account, error) {
+	var errs error
+	result := make([]dto.Account, 0)
+	for _, account := range accounts {
+		resources := make([]dto.Resource, 0)
+		for _, region := range regions {
+			ctx, logger := log.UpdateContext(ctx, "account:region", clientKey(account, region))
+			found, err := findRegionOrphans(ctx, account, region)
+			if err != nil {
+				logger.Warnf("finding orphans failed, skipping region: %s", err.Error())
+				errs = multierr.Append(errs, fmt.Errorf("%s: %w", clientKey(account, region), err))
+			}
+			resources = append(resources, found...)
+		}
+		if len(resources) > 0 {
+			result = append(result, dto.Account{Name: account, Resources: resources})
+		}
+	}
+	return result, errs
+}
+
+// Returns orphans of the region, orphans found before an error are returned with it
+func findRegionOrphans(ctx context.Context, account, region string) ([]dto.Resource, error) {
+	logger := log.FromContext(ctx)
+	cls, err := initClients(ctx, account, region)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("listing tagged cluster names")
+	names, err := listTaggedClusterNames(ctx, cls)
+	if err != nil {
+		return nil, err
+	}
+	logger.Infof("checking clusters: %d", len(names))
+	resources := make([]dto.Resource, 0)
+	for _, name := range names {
+		_, err := listEKS(ctx, cls.EKS, name)
+		if err == nil {
+			continue
+		}
+		if !errors.As(err, &eksNotFoundErr) {
+			return resources, err
+		}
+		logger.Infof("found orphaned resources of cluster: %s", name)
+		resources = append(resources, dto.Resource{
+			Name:     name,
+			Location: region,
+			Expiry:   time.Now(),
+		})
+	}
+	return resources, nil
+}
+
+// Cluster names found in resource tags
+type taggedClusters struct {
+	// names with EKS specific or `kubernetes.io/cluster/<name>` tags
+	eks map[string]struct{}
+	// names with `KubernetesCluster` tag
+	selfManaged map[string]struct{}
+}
+
+func newTaggedClusters() *taggedClusters {
+	return &taggedClusters{eks: make(map[string]struct{}), selfManaged: make(map[string]struct{})}
+}
+
+func (c *taggedClusters) add(key, value string) {
+	if _, ok := eksClusterNameTags[key]; ok && value != "" {
+		c.eks[value] = struct{}{}
+	}
+	if name, ok := strings.CutPrefix(key, kubernetesClusterTagPrefix); ok && name != "" {
+		c.eks[name] = struct{}{}
+	}
+	if key == kubernetesClusterTag && value != "" {
+		c.selfManaged[value] = struct{}{}
+	}
+}
+
+// Returns sorted cluster names to check with EKS API, names tagged as self-managed are skipped
+func (c *taggedClusters) eksClusterNames() []string {
+	result := make([]string, 0, len(c.eks))
+	for name := range c.eks {
+		if _, ok := c.selfManaged[name]; ok {
+			continue
+		}
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Returns sorted cluster names found in EC2, AutoScaling and CloudFormation resource tags
+func listTaggedClusterNames(ctx context.Context, clients *clients) ([]string, error) {
+	clusters := newTaggedClusters()
+	if err := listEC2ClusterTags(ctx, clients.EC2, clusters.add); err != nil {
+		return nil, err
+	}
+	if err := listAutoScalingClusterTags(ctx, clients.ASG, clusters.add); err != nil {
+		return nil, err
+	}
+	if err := listCloudFormationClusterTags(ctx, clients.CF, clusters.add); err != nil {
+		return nil, err
+	}
+	return clusters.eksClusterNames(), nil
+}
+
+func listEC2ClusterTags(ctx context.Context, client *ec2.Client, add func(key, value string)) error {
+	input := ec2.DescribeTagsInput{
+		Filters: []types.Filter{
+			{
+				Name:   aws.String("key"),
+				Values: append(tagKeys(eksClusterNameTags), kubernetesClusterTag, kubernetesClusterTagPrefix+"*"),
+			},
+		},
+	}
+	for {
+		output, err := client.DescribeTags(ctx, &input)
+		if err != nil {
+			return err
+		}
+		for _, tag := range output.Tags {
+			add(aws.ToString(tag.Key), aws.ToString(tag.Value))
+		}
+		if output.NextToken == nil {
+			return nil
+		}
+		input.NextToken = output.NextToken
+	}
+}
+
+func listAutoScalingClusterTags(ctx context.Context, client *autoscaling.Client, add func(key, value string)) error {
+	groups, err := describeAutoScalingGroups(ctx, client, nil)
+	if err != nil {
+		return err
+	}
+	for _, group := range groups {
+		for _, tag := range group.Tags {
+			add(aws.ToString(tag.Key), aws.ToString(tag.Value))
+		}
+	}
+	return nil
+}
+
+func listCloudFormationClusterTags(ctx context.Context, client *cloudformation.Client, add func(key, value string)) error {
+	var nextToken *string
+	for {
+		res, err := client.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{
+			NextToken: nextToken,
+		})
+		if err != nil {
+			return err
+		}
+		for _, stack := range res.Stacks {
+			for _, tag := range stack.Tags {
+				_, ok := clusterTags[aws.ToString(tag.Key)]
+				if ok || strings.HasPrefix(aws.ToString(tag.Key), kubernetesClusterTagPrefix) {
+					add(aws.ToString(tag.Key), aws.ToString(tag.Value))
+				}
+			}
+		}
+		if res.NextToken == nil {
+			return nil
+		}
+		nextToken = res.NextToken
+	}
+}
+
+func deleteOrphanCluster(ctx context.Context, cls *clients, resource dto.Resource, opts DeleteOptions) error {
+	clusterName := resource.Name
+	logger := log.FromContext(ctx)
+	logger.Info("checking cluster does not exist")
+	if _, err := listEKS(ctx, cls.EKS, clusterName); err == nil {
+		logger.Warn("cluster exists, skipping orphan cleanup")
+		return nil
+	} else if !errors.As(err, &eksNotFoundErr) {
+		return err
+	}
+	logger.Info("finding cluster vpc")
+	vpcIDs, err := listClusterVpcs(ctx, cls.EC2, clusterName)
+	if err != nil {
+		return err
+	}
+	return withRetries(ctx, opts, func() error {
+		errs := deleteK8sEC2(ctx, cls, vpcIDs, clusterName, opts)
+		logger.Info("deleting cloud formation")
+		if err := deleteCloudFormation(ctx, cls.CF, clusterName); err != nil {
+			errs = multierr.Append(errs, err)
+		}
+		if err := deleteClusterRegionalResources(ctx, cls, clusterName, nil, opts); err != nil {
+			errs = multierr.Append(errs, err)
+		}
+		return errs
+	})
+}
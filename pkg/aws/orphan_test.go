@@ -0,0 +1,26 @@
+package aws_test
+
+import (
+	"testing"
+
+	"c7n-helper/pkg/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaggedClusterNames(t *testing.T) {
+	tags := []aws.KeyValue{
+		// EKS cluster created without EKS specific tags, checked by the prefix tag
+		{Key: "kubernetes.io/cluster/eks-3", Value: "owned"},
+		{Key: "karpenter.sh/discovery", Value: "eks-3"},
+		// self-managed kops cluster
+		{Key: "kubernetes.io/cluster/kops-1", Value: "owned"},
+		{Key: "KubernetesCluster", Value: "kops-1"},
+		{Key: "kubernetes.io/cluster/eks-1", Value: "owned"},
+		{Key: "eks:cluster-name", Value: "eks-1"},
+		{Key: "aws:eks:cluster-name", Value: "eks-2"},
+		{Key: "alpha.eksctl.io/cluster-name", Value: "eksctl-1"},
+		{Key: "eks:cluster-name", Value: "mixed"},
+		{Key: "KubernetesCluster", Value: "mixed"},
+	}
+	assert.Equal(t, []string{"eks-1", "eks-2", "eks-3", "eksctl-1"}, aws.TaggedClusterNames(tags))
+}
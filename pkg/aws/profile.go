@@ -0,0 +1,63 @@
+package aws
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// SharedConfigProfiles returns profile names from AWS shared config and credentials files
+func SharedConfigProfiles() ([]string, error) {
+	configFile := config.DefaultSharedConfigFilename()
+	if file := os.Getenv("AWS_CONFIG_FILE"); file != "" {
+		configFile = file
+	}
+	credentialsFile := config.DefaultSharedCredentialsFilename()
+	if file := os.Getenv("AWS_SHARED_CREDENTIALS_FILE"); file != "" {
+		credentialsFile = file
+	}
+	profiles := make(map[string]struct{})
+	for _, file := range []string{configFile, credentialsFile} {
+		if err := readProfiles(file, profiles); err != nil {
+			return nil, err
+		}
+	}
+	result := make([]string, 0, len(profiles))
+	for profile := range profiles {
+		result = append(result, profile)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// Reads INI section names: `[profile <name>]` or `[default]` in config file and `[<name>]` in credentials file
+func readProfiles(file string, profiles map[string]struct{}) error {
+	f, err := os.Open(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "[") || !strings.HasSuffix(line, "]") {
+			continue
+		}
+		section := strings.TrimSpace(line[1 : len(line)-1])
+		if name, ok := strings.CutPrefix(section, "profile "); ok {
+			section = strings.TrimSpace(name)
+		} else if strings.Contains(section, " ") {
+			// `sso-session <name>`, `services <name>` and other non-profile sections
+			continue
+		}
+		profiles[section] = struct{}{}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,425 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"c7n-helper/pkg/dto"
+	"c7n-helper/pkg/log"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	ekstypes "github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/aws/smithy-go"
+	"go.uber.org/multierr"
+)
+
+const (
+	// Quarantine time in RFC3339 format
+	quarantinedTag = "c7n-helper/quarantined"
+	// Space separated original EKS public endpoint access CIDRs
+	originalPublicAccessCidrsTag = "c7n-helper/original-public-access-cidrs"
+	// Space separated original network interface security group IDs
+	originalSecurityGroupsTag = "c7n-helper/original-security-groups"
+	quarantineSecurityGroup   = "c7n-helper-quarantine"
+
+	clusterUpdatePollInterval = time.Second * 15
+	clusterUpdateMaxDuration  = time.Minute * 30
+)
+
+type quarantineAction func(ctx context.Context, clients *clients, resource dto.Resource, publicAccessCidrs []string) error
+
+// Only types that clean can delete later are quarantinable
+var resourceQuarantines = map[string]quarantineAction{
+	"eks": quarantineEKSCluster,
+}
+
+var resourceUnquarantines = map[string]resourceAction{
+	"eks": unquarantineEKSCluster,
+}
+
+func IsQuarantinable(resourceType string) bool {
+	_, ok := resourceQuarantines[resourceType]
+	return ok
+}
+
+// QuarantineResources makes resources unusable: restricts EKS public endpoint access CIDRs and replaces cluster instances
+// network interfaces security groups with deny-all group. Original state is saved in tags.
+func QuarantineResources(ctx context.Context, resourceType string, accounts []dto.Account, publicAccessCidrs []string) error {
+	quarantine, ok := resourceQuarantines[resourceType]
+	if !ok {
+		return errors.New("unsupported resource type")
+	}
+	return forEachResource(ctx, resourceType, accounts, func(ctx context.Context, cls *clients, resource dto.Resource) error {
+		return quarantine(ctx, cls, resource, publicAccessCidrs)
+	})
+}
+
+// UnquarantineResources restores resources state saved by QuarantineResources
+func UnquarantineResources(ctx context.Context, resourceType string, accounts []dto.Account) error {
+	unquarantine, ok := resourceUnquarantines[resourceType]
+	if !ok {
+		return errors.New("unsupported resource type")
+	}
+	return forEachResource(ctx, resourceType, accounts, unquarantine)
+}
+
+func quarantineEKSCluster(ctx context.Context, clients *clients, resource dto.Resource, publicAccessCidrs []string) error {
+	logger := log.FromContext(ctx)
+	cluster, err := listEKS(ctx, clients.EKS, resource.Name)
+	if errors.As(err, &eksNotFoundErr) {
+		logger.Info("cluster not found, skipping")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// the current access is checked on each run, the quarantine tag doesn't prove a previous update succeeded
+	vpcConfig := cluster.ResourcesVpcConfig
+	if vpcConfig != nil && vpcConfig.EndpointPublicAccess && !sameCidrs(vpcConfig.PublicAccessCidrs, publicAccessCidrs) {
+		if _, ok := cluster.Tags[originalPublicAccessCidrsTag]; !ok {
+			_, err := clients.EKS.TagResource(ctx, &eks.TagResourceInput{
+				ResourceArn: cluster.Arn,
+				Tags:        map[string]string{originalPublicAccessCidrsTag: strings.Join(vpcConfig.PublicAccessCidrs, " ")},
+			})
+			if err != nil {
+				return err
+			}
+		}
+		logger.Infof("restricting public endpoint access to: %s", strings.Join(publicAccessCidrs, ","))
+		if err := updatePublicAccessCidrs(ctx, clients.EKS, resource.Name, publicAccessCidrs); err != nil {
+			return err
+		}
+	}
+	// tagged only after the endpoint is restricted, so clean doesn't count a failed quarantine
+	if _, ok := cluster.Tags[quarantinedTag]; !ok {
+		_, err := clients.EKS.TagResource(ctx, &eks.TagResourceInput{
+			ResourceArn: cluster.Arn,
+			Tags:        map[string]string{quarantinedTag: time.Now().UTC().Format(time.RFC3339)},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	logger.Info("listing cluster instances")
+	instances, err := listClusterInstances(ctx, clients.EC2, resource.Name)
+	if err != nil {
+		return err
+	}
+	logger.Infof("quarantining cluster instances: %d", len(instances))
+	var errs error
+	for _, instance := range instances {
+		errs = multierr.Append(errs, quarantineInstance(ctx, clients.EC2, instance))
+	}
+	return errs
+}
+
+func unquarantineEKSCluster(ctx context.Context, clients *clients, resource dto.Resource) error {
+	logger := log.FromContext(ctx)
+	cluster, err := listEKS(ctx, clients.EKS, resource.Name)
+	if errors.As(err, &eksNotFoundErr) {
+		logger.Info("cluster not found, skipping")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	logger.Info("listing cluster instances")
+	instances, err := listClusterInstances(ctx, clients.EC2, resource.Name)
+	if err != nil {
+		return err
+	}
+	logger.Infof("restoring cluster instances security groups: %d", len(instances))
+	var errs error
+	for _, instance := range instances {
+		errs = multierr.Append(errs, unquarantineInstance(ctx, clients.EC2, instance))
+	}
+	if errs != nil {
+		return errs
+	}
+	if cidrs, ok := cluster.Tags[originalPublicAccessCidrsTag]; ok {
+		logger.Infof("restoring public endpoint access: %s", cidrs)
+		if err := updatePublicAccessCidrs(ctx, clients.EKS, resource.Name, strings.Fields(cidrs)); err != nil {
+			return err
+		}
+	}
+	_, err = clients.EKS.UntagResource(ctx, &eks.UntagResourceInput{
+		ResourceArn: cluster.Arn,
+		TagKeys:     []string{quarantinedTag, originalPublicAccessCidrsTag},
+	})
+	return err
+}
+
+// Replaces security groups of each instance network interface with deny-all group, original groups are saved
+// in the network interface tag, so instances with many network interfaces (e.g. VPC CNI) are quarantined too
+func quarantineInstance(ctx context.Context, client *ec2.Client, instance ec2types.Instance) error {
+	if instance.VpcId == nil {
+		return nil
+	}
+	logger := log.FromContext(ctx)
+	interfaces, err := describeInstanceNetworkInterfaces(ctx, client, instance)
+	if err != nil {
+		return err
+	}
+	denyAllGroupId, err := ensureQuarantineSecurityGroup(ctx, client, *instance.VpcId)
+	if err != nil {
+		return err
+	}
+	if _, ok := ec2Tag(instance.Tags, quarantinedTag); !ok {
+		_, err = client.CreateTags(ctx, &ec2.CreateTagsInput{
+			Resources: []string{aws.ToString(instance.InstanceId)},
+			Tags:      []ec2types.Tag{{Key: aws.String(quarantinedTag), Value: aws.String(time.Now().UTC().Format(time.RFC3339))}},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	var errs error
+	for _, networkInterface := range interfaces {
+		if _, ok := ec2Tag(networkInterface.TagSet, originalSecurityGroupsTag); ok {
+			continue
+		}
+		groupIds := make([]string, 0, len(networkInterface.Groups))
+		for _, group := range networkInterface.Groups {
+			groupIds = append(groupIds, aws.ToString(group.GroupId))
+		}
+		_, err := client.CreateTags(ctx, &ec2.CreateTagsInput{
+			Resources: []string{aws.ToString(networkInterface.NetworkInterfaceId)},
+			Tags:      []ec2types.Tag{{Key: aws.String(originalSecurityGroupsTag), Value: aws.String(strings.Join(groupIds, " "))}},
+		})
+		if err != nil {
+			errs = multierr.Append(errs, err)
+			continue
+		}
+		logger.Infof("replacing instance %s network interface %s security groups %s with %s", aws.ToString(instance.InstanceId),
+			aws.ToString(networkInterface.NetworkInterfaceId), strings.Join(groupIds, ","), denyAllGroupId)
+		_, err = client.ModifyNetworkInterfaceAttribute(ctx, &ec2.ModifyNetworkInterfaceAttributeInput{
+			NetworkInterfaceId: networkInterface.NetworkInterfaceId,
+			Groups:             []string{denyAllGroupId},
+		})
+		errs = multierr.Append(errs, err)
+	}
+	return errs
+}
+
+// Restores security groups of each instance network interface saved by quarantineInstance
+func unquarantineInstance(ctx context.Context, client *ec2.Client, instance ec2types.Instance) error {
+	logger := log.FromContext(ctx)
+	interfaces, err := describeInstanceNetworkInterfaces(ctx, client, instance)
+	if err != nil {
+		return err
+	}
+	var errs error
+	for _, networkInterface := range interfaces {
+		groups, ok := ec2Tag(networkInterface.TagSet, originalSecurityGroupsTag)
+		if !ok {
+			continue
+		}
+		logger.Infof("restoring instance %s network interface %s security groups: %s", aws.ToString(instance.InstanceId),
+			aws.ToString(networkInterface.NetworkInterfaceId), groups)
+		_, err := client.ModifyNetworkInterfaceAttribute(ctx, &ec2.ModifyNetworkInterfaceAttributeInput{
+			NetworkInterfaceId: networkInterface.NetworkInterfaceId,
+			Groups:             strings.Fields(groups),
+		})
+		if err != nil {
+			errs = multierr.Append(errs, err)
+			continue
+		}
+		_, err = client.DeleteTags(ctx, &ec2.DeleteTagsInput{
+			Resources: []string{aws.ToString(networkInterface.NetworkInterfaceId)},
+			Tags:      []ec2types.Tag{{Key: aws.String(originalSecurityGroupsTag)}},
+		})
+		errs = multierr.Append(errs, err)
+	}
+	if errs != nil {
+		return errs
+	}
+	_, err = client.DeleteTags(ctx, &ec2.DeleteTagsInput{
+		Resources: []string{aws.ToString(instance.InstanceId)},
+		Tags:      []ec2types.Tag{{Key: aws.String(quarantinedTag)}},
+	})
+	return err
+}
+
+// Returns all network interfaces attached to the instance with their tags
+func describeInstanceNetworkInterfaces(ctx context.Context, client *ec2.Client, instance ec2types.Instance) ([]ec2types.NetworkInterface, error) {
+	ids := make([]string, 0, len(instance.NetworkInterfaces))
+	for _, networkInterface := range instance.NetworkInterfaces {
+		ids = append(ids, aws.ToString(networkInterface.NetworkInterfaceId))
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	output, err := client.DescribeNetworkInterfaces(ctx, &ec2.DescribeNetworkInterfacesInput{NetworkInterfaceIds: ids})
+	if err != nil {
+		return nil, err
+	}
+	return output.NetworkInterfaces, nil
+}
+
+// Returns the VPC deny-all security group ID, the group is created if it doesn't exist.
+// Rules of the group are removed each time: a new group allows all egress traffic by default and a previous run
+// could fail before revoking it, so an existing group is not trusted to be deny-all.
+func ensureQuarantineSecurityGroup(ctx context.Context, client *ec2.Client, vpcId string) (string, error) {
+	groupId, err := findQuarantineSecurityGroup(ctx, client, vpcId)
+	if err != nil {
+		return "", err
+	}
+	if groupId == "" {
+		created, err := client.CreateSecurityGroup(ctx, &ec2.CreateSecurityGroupInput{
+			GroupName:   aws.String(quarantineSecurityGroup),
+			Description: aws.String("Deny all traffic of instances quarantined by c7n-helper"),
+			VpcId:       aws.String(vpcId),
+		})
+		// another quarantine in the same VPC created the group concurrently
+		if apiErr := (smithy.APIError)(nil); errors.As(err, &apiErr) && apiErr.ErrorCode() == "InvalidGroup.Duplicate" {
+			if groupId, err = findQuarantineSecurityGroup(ctx, client, vpcId); err == nil && groupId == "" {
+				err = fmt.Errorf("security group %s is not found after duplicate error", quarantineSecurityGroup)
+			}
+		} else if err == nil {
+			groupId = aws.ToString(created.GroupId)
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	rules, err := listSecurityGroupRules(ctx, client, groupId)
+	if err != nil {
+		return "", err
+	}
+	if len(rules) == 0 {
+		return groupId, nil
+	}
+	log.FromContext(ctx).Infof("removing %d rules of quarantine security group %s", len(rules), groupId)
+	if err := deleteSecurityGroupRules(ctx, client, groupId, rules); err != nil {
+		// a concurrent quarantine in the same VPC could revoke the same rules first
+		if rules, listErr := listSecurityGroupRules(ctx, client, groupId); listErr != nil || len(rules) > 0 {
+			return "", err
+		}
+	}
+	return groupId, nil
+}
+
+// Returns the VPC quarantine security group ID, empty if the group doesn't exist
+func findQuarantineSecurityGroup(ctx context.Context, client *ec2.Client, vpcId string) (string, error) {
+	output, err := client.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{
+		Filters: append(ec2VpcFilter(vpcId), ec2types.Filter{
+			Name:   aws.String("group-name"),
+			Values: []string{quarantineSecurityGroup},
+		}),
+	})
+	if err != nil || len(output.SecurityGroups) == 0 {
+		return "", err
+	}
+	return aws.ToString(output.SecurityGroups[0].GroupId), nil
+}
+
+// Updates the cluster public endpoint access CIDRs and waits until the asynchronous update succeeds
+func updatePublicAccessCidrs(ctx context.Context, client *eks.Client, clusterName string, cidrs []string) error {
+	output, err := client.UpdateClusterConfig(ctx, &eks.UpdateClusterConfigInput{
+		Name: aws.String(clusterName),
+		ResourcesVpcConfig: &ekstypes.VpcConfigRequest{
+			PublicAccessCidrs: cidrs,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(clusterUpdateMaxDuration)
+	for {
+		update, err := client.DescribeUpdate(ctx, &eks.DescribeUpdateInput{
+			Name:     aws.String(clusterName),
+			UpdateId: output.Update.Id,
+		})
+		if err != nil {
+			return err
+		}
+		switch update.Update.Status {
+		case ekstypes.UpdateStatusSuccessful:
+			return nil
+		case ekstypes.UpdateStatusFailed, ekstypes.UpdateStatusCancelled:
+			messages := make([]string, 0, len(update.Update.Errors))
+			for _, updateErr := range update.Update.Errors {
+				messages = append(messages, aws.ToString(updateErr.ErrorMessage))
+			}
+			return fmt.Errorf("cluster update %s %s: %s", aws.ToString(output.Update.Id), update.Update.Status, strings.Join(messages, "; "))
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("cluster update %s is not finished in %s", aws.ToString(output.Update.Id), clusterUpdateMaxDuration)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(clusterUpdatePollInterval):
+		}
+	}
+}
+
+// Compares CIDR lists ignoring the order
+func sameCidrs(current, expected []string) bool {
+	if len(current) != len(expected) {
+		return false
+	}
+	set := make(map[string]struct{}, len(current))
+	for _, cidr := range current {
+		set[cidr] = struct{}{}
+	}
+	for _, cidr := range expected {
+		if _, ok := set[cidr]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns non-terminated instances tagged for the cluster
+func listClusterInstances(ctx context.Context, client *ec2.Client, clusterName string) ([]ec2types.Instance, error) {
+	reservations, err := listClusterReservations(ctx, client, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	instances := make([]ec2types.Instance, 0)
+	for _, reservation := range reservations {
+		for _, instance := range reservation.Instances {
+			if instance.State != nil && (instance.State.Name == ec2types.InstanceStateNameTerminated ||
+				instance.State.Name == ec2types.InstanceStateNameShuttingDown) {
+				continue
+			}
+			instances = append(instances, instance)
+		}
+	}
+	return instances, nil
+}
+
+// Returns resource quarantine time, false if the resource is not quarantined
+func quarantineTime(ctx context.Context, clients *clients, resourceType string, resource dto.Resource) (time.Time, bool, error) {
+	value, err := resourceTag(ctx, clients, resourceType, resource, quarantinedTag)
+	if err != nil || value == "" {
+		return time.Time{}, false, err
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return t, true, nil
+}
+
+// Returns current tag value of EKS cluster, empty if the tag or the resource is not found
+func resourceTag(ctx context.Context, clients *clients, resourceType string, resource dto.Resource, key string) (string, error) {
+	switch resourceType {
+	case "eks":
+		cluster, err := listEKS(ctx, clients.EKS, resource.Name)
+		if errors.As(err, &eksNotFoundErr) {
+			return "", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		return cluster.Tags[key], nil
+	}
+	return "", nil
+}
@@ -0,0 +1,155 @@
+package aws
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"c7n-helper/pkg/log"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"go.uber.org/multierr"
+)
+
+// ChangeResourceRecordSets accepts up to 1000 changes in a batch
+const maxRecordChangesPerBatch = 1000
+
+/*
+Private hosted zones associated with the VPC block the VPC deletion.
+Zones tagged for the cluster are deleted (with all the records), other zones are disassociated from the VPC
+if they are associated with another VPC too (the last VPC can't be disassociated from a private zone).
+*/
+func deletePrivateHostedZones(ctx context.Context, client *route53.Client, region, vpcId, clusterName string) (errs error) {
+	logger := log.FromContext(ctx)
+	zones, err := listVpcHostedZones(ctx, client, region, vpcId)
+	if err != nil {
+		return err
+	}
+	logger.Infof("found private hosted zones: %d", len(zones))
+	for _, zone := range zones {
+		if zone.HostedZoneId == nil {
+			continue
+		}
+		if zone.Owner != nil && zone.Owner.OwningService != nil {
+			logger.Infof("private hosted zone %s is owned by %s, skipping", *zone.HostedZoneId, *zone.Owner.OwningService)
+			continue
+		}
+		zoneId := strings.TrimPrefix(*zone.HostedZoneId, "/hostedzone/")
+		owned, err := isHostedZoneTaggedForCluster(ctx, client, zoneId, clusterName)
+		if err != nil {
+			errs = multierr.Append(errs, err)
+			continue
+		}
+		if owned {
+			logger.Infof("deleting private hosted zone %s [%s]", zoneId, aws.ToString(zone.Name))
+			errs = multierr.Append(errs, deleteHostedZone(ctx, client, zoneId))
+			continue
+		}
+		output, err := client.GetHostedZone(ctx, &route53.GetHostedZoneInput{Id: aws.String(zoneId)})
+		if err != nil {
+			errs = multierr.Append(errs, err)
+			continue
+		}
+		if len(output.VPCs) < 2 {
+			logger.Warnf("private hosted zone %s [%s] is not tagged for the cluster and associated only with the vpc, skipping", zoneId, aws.ToString(zone.Name))
+			continue
+		}
+		logger.Infof("disassociating private hosted zone %s [%s] from the vpc", zoneId, aws.ToString(zone.Name))
+		_, err = client.DisassociateVPCFromHostedZone(ctx, &route53.DisassociateVPCFromHostedZoneInput{
+			HostedZoneId: aws.String(zoneId),
+			VPC: &types.VPC{
+				VPCId:     aws.String(vpcId),
+				VPCRegion: types.VPCRegion(region),
+			},
+		})
+		errs = multierr.Append(errs, err)
+	}
+	return
+}
+
+func listVpcHostedZones(ctx context.Context, client *route53.Client, region, vpcId string) ([]types.HostedZoneSummary, error) {
+	input := route53.ListHostedZonesByVPCInput{
+		VPCId:     aws.String(vpcId),
+		VPCRegion: types.VPCRegion(region),
+	}
+	var zones []types.HostedZoneSummary
+	for {
+		output, err := client.ListHostedZonesByVPC(ctx, &input)
+		if err != nil {
+			return nil, err
+		}
+		zones = append(zones, output.HostedZoneSummaries...)
+		if output.NextToken == nil {
+			return zones, nil
+		}
+		input.NextToken = output.NextToken
+	}
+}
+
+func isHostedZoneTaggedForCluster(ctx context.Context, client *route53.Client, zoneId, clusterName string) (bool, error) {
+	output, err := client.ListTagsForResource(ctx, &route53.ListTagsForResourceInput{
+		ResourceId:   aws.String(zoneId),
+		ResourceType: types.TagResourceTypeHostedzone,
+	})
+	if err != nil {
+		return false, err
+	}
+	if output.ResourceTagSet == nil {
+		return false, nil
+	}
+	for _, tag := range output.ResourceTagSet.Tags {
+		if clusterNameFromTag(aws.ToString(tag.Key), aws.ToString(tag.Value)) == clusterName {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Hosted zone can be deleted only if it contains only the default SOA and NS records
+func deleteHostedZone(ctx context.Context, client *route53.Client, zoneId string) error {
+	input := route53.ListResourceRecordSetsInput{
+		HostedZoneId: aws.String(zoneId),
+	}
+	var changes []types.Change
+	var zoneName string
+	for {
+		output, err := client.ListResourceRecordSets(ctx, &input)
+		if err != nil {
+			return err
+		}
+		for _, recordSet := range output.ResourceRecordSets {
+			if recordSet.Type == types.RRTypeSoa {
+				zoneName = aws.ToString(recordSet.Name)
+			}
+		}
+		for _, recordSet := range output.ResourceRecordSets {
+			if recordSet.Type == types.RRTypeSoa || (recordSet.Type == types.RRTypeNs && aws.ToString(recordSet.Name) == zoneName) {
+				continue
+			}
+			changes = append(changes, types.Change{
+				Action:            types.ChangeActionDelete,
+				ResourceRecordSet: &recordSet,
+			})
+		}
+		if !output.IsTruncated {
+			break
+		}
+		input.StartRecordName = output.NextRecordName
+		input.StartRecordType = output.NextRecordType
+		input.StartRecordIdentifier = output.NextRecordIdentifier
+	}
+	for batch := range slices.Chunk(changes, maxRecordChangesPerBatch) {
+		_, err := client.ChangeResourceRecordSets(ctx, &route53.ChangeResourceRecordSetsInput{
+			HostedZoneId: aws.String(zoneId),
+			ChangeBatch:  &types.ChangeBatch{Changes: batch},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	_, err := client.DeleteHostedZone(ctx, &route53.DeleteHostedZoneInput{
+		Id: aws.String(zoneId),
+	})
+	return err
+}
@@ -1,51 +1,50 @@
 package aws
 
 import (
-	"encoding/json"
-	"strings"
+	"io"
 	"time"
 
 	"c7n-helper/pkg/date"
 	"c7n-helper/pkg/dto"
+	"c7n-helper/pkg/expiry"
+	"c7n-helper/pkg/jsonstream"
+	"c7n-helper/pkg/tagschema"
 )
 
 type location struct {
 	LocationConstraint string `json:"LocationConstraint"`
 }
 
-func ParseS3(region string, content []byte) ([]dto.Resource, error) {
-	var buckets []struct {
-		Name      string     `json:"Name"`
-		CreatedAt time.Time  `json:"CreationDate"`
-		Tags      []keyValue `json:"Tags"`
-		Location  location   `json:"Location"`
-	}
-	if err := json.Unmarshal(content, &buckets); err != nil {
-		return nil, err
-	}
-	result := make([]dto.Resource, 0, len(buckets))
-	for _, bucket := range buckets {
+type s3Bucket struct {
+	Name      string     `json:"Name"`
+	CreatedAt time.Time  `json:"CreationDate"`
+	Tags      []keyValue `json:"Tags"`
+	Location  location   `json:"Location"`
+}
+
+func ParseS3(region string, r io.Reader, schema tagschema.Config) ([]dto.Resource, error) {
+	result := make([]dto.Resource, 0)
+	err := jsonstream.DecodeArray(r, func(bucket s3Bucket) error {
 		if bucket.Location.LocationConstraint != region {
 			// custodian returns all buckets for each region
 			// skip buckets from another regions
-			continue
-		}
-		owner, expiry := "", ""
-		for _, tag := range bucket.Tags {
-			switch strings.ToLower(tag.Key) {
-			case "owner":
-				owner = tag.Value
-			case "expiry":
-				expiry = tag.Value
-			}
+			return nil
 		}
+		tags := tagMap(bucket.Tags)
+		values := schema.AWS.Resolve(tags)
+		expiresAt, expiryStatus := expiry.Resolve(values.Expiry, bucket.CreatedAt, time.Now())
 		result = append(result, dto.Resource{
-			Name:     bucket.Name,
-			Location: region,
-			Owner:    owner,
-			Created:  bucket.CreatedAt,
-			Expiry:   date.ParseOrDefault(expiry, time.Now()),
+			Name:         bucket.Name,
+			Location:     region,
+			Owner:        values.Owner,
+			Team:         values.Team,
+			Created:      bucket.CreatedAt,
+			Expiry:       expiresAt,
+			ExpiryStatus: expiryStatus,
+			DeleteAfter:  date.ParseOrDefault(tags[deleteAfterTag], time.Time{}),
+			Sources:      values.Sources,
 		})
-	}
-	return result, nil
+		return nil
+	})
+	return result, err
 }
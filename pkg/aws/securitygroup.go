@@ -2,13 +2,25 @@ package aws
 
 import (
 	"context"
+	"strings"
 
+	"c7n-helper/pkg/log"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"go.uber.org/multierr"
 )
 
 func deleteSecurityGroups(ctx context.Context, client *ec2.Client, vpcId string, securityGroups []types.SecurityGroup) (errs error) {
+	groupIds := make([]string, 0, len(securityGroups))
+	for _, securityGroup := range securityGroups {
+		if securityGroup.GroupId != nil && securityGroup.VpcId != nil && *securityGroup.VpcId == vpcId {
+			groupIds = append(groupIds, *securityGroup.GroupId)
+		}
+	}
+	if err := revokeExternalSecurityGroupReferences(ctx, client, vpcId, groupIds); err != nil {
+		errs = multierr.Append(errs, err)
+	}
 	for _, securityGroup := range securityGroups {
 		if securityGroup.GroupId == nil {
 			continue
@@ -54,3 +66,87 @@ func listNonDefaultSecurityGroups(ctx context.Context, client *ec2.Client, vpcId
 		input.NextToken = output.NextToken
 	}
 }
+
+// Revokes rules of security groups from other VPCs (peered or in the same account) that reference the given groups,
+// otherwise the groups can't be deleted
+func revokeExternalSecurityGroupReferences(ctx context.Context, client *ec2.Client, vpcId string, groupIds []string) (errs error) {
+	if len(groupIds) == 0 {
+		return nil
+	}
+	referencingGroups, err := listReferencingSecurityGroups(ctx, client, groupIds)
+	if err != nil {
+		return err
+	}
+	doomed := make(map[string]struct{}, len(groupIds))
+	for _, groupId := range groupIds {
+		doomed[groupId] = struct{}{}
+	}
+	logger := log.FromContext(ctx)
+	for _, securityGroup := range referencingGroups {
+		if securityGroup.GroupId == nil || (securityGroup.VpcId != nil && *securityGroup.VpcId == vpcId) {
+			continue
+		}
+		groupId := *securityGroup.GroupId
+		securityGroupRules, err := listSecurityGroupRules(ctx, client, groupId)
+		if err != nil {
+			errs = multierr.Append(errs, err)
+			continue
+		}
+		referencingRules := make([]types.SecurityGroupRule, 0)
+		referencedIds := make([]string, 0)
+		for _, securityGroupRule := range securityGroupRules {
+			if securityGroupRule.ReferencedGroupInfo == nil || securityGroupRule.ReferencedGroupInfo.GroupId == nil {
+				continue
+			}
+			if _, ok := doomed[*securityGroupRule.ReferencedGroupInfo.GroupId]; !ok {
+				continue
+			}
+			referencingRules = append(referencingRules, securityGroupRule)
+			referencedIds = append(referencedIds, *securityGroupRule.ReferencedGroupInfo.GroupId)
+		}
+		if len(referencingRules) == 0 {
+			continue
+		}
+		logger.Infof("revoking rules %s of external security group %s [vpc: %s] referencing security groups: %s",
+			strings.Join(securityGroupRuleIds(referencingRules), ","), groupId,
+			aws.ToString(securityGroup.VpcId), strings.Join(referencedIds, ","))
+		errs = multierr.Append(errs, deleteSecurityGroupRules(ctx, client, groupId, referencingRules))
+	}
+	return
+}
+
+func listReferencingSecurityGroups(ctx context.Context, client *ec2.Client, groupIds []string) ([]types.SecurityGroup, error) {
+	var securityGroups []types.SecurityGroup
+	found := make(map[string]struct{})
+	for _, name := range []string{"ip-permission.group-id", "egress.ip-permission.group-id"} {
+		input := ec2.DescribeSecurityGroupsInput{
+			Filters: []types.Filter{
+				{
+					Name:   aws.String(name),
+					Values: groupIds,
+				},
+			},
+		}
+		for {
+			output, err := client.DescribeSecurityGroups(ctx, &input)
+			if err != nil {
+				return nil, err
+			}
+			for _, securityGroup := range output.SecurityGroups {
+				if securityGroup.GroupId == nil {
+					continue
+				}
+				if _, ok := found[*securityGroup.GroupId]; ok {
+					continue
+				}
+				found[*securityGroup.GroupId] = struct{}{}
+				securityGroups = append(securityGroups, securityGroup)
+			}
+			if output.NextToken == nil {
+				break
+			}
+			input.NextToken = output.NextToken
+		}
+	}
+	return securityGroups, nil
+}
@@ -0,0 +1,346 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"c7n-helper/pkg/dto"
+	"c7n-helper/pkg/log"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/autoscaling"
+	asgtypes "github.com/aws/aws-sdk-go-v2/service/autoscaling/types"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	ekstypes "github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"go.uber.org/multierr"
+)
+
+const (
+	// Original scaling of stopped node groups and autoscaling groups: `min=<n> max=<n> desired=<n>`
+	originalCapacityTag = "c7n-helper/original-capacity"
+	// Stop time of EC2 instances stopped by the helper
+	stoppedTag           = "c7n-helper/stopped"
+	originalCapacityForm = "min=%d max=%d desired=%d"
+	// EKS managed node groups autoscaling groups are scaled through node group API
+	eksNodeGroupNameTag = "eks:nodegroup-name"
+)
+
+type resourceAction func(ctx context.Context, clients *clients, resource dto.Resource) error
+
+var resourceStoppers = map[string]resourceAction{
+	"eks": stopEKSCluster,
+	"ec2": stopEC2Instance,
+}
+
+var resourceStarters = map[string]resourceAction{
+	"eks": startEKSCluster,
+	"ec2": startEC2Instance,
+}
+
+func IsStoppable(resourceType string) bool {
+	_, ok := resourceStoppers[resourceType]
+	return ok
+}
+
+// StopResources scales EKS clusters to zero and stops EC2 instances, original state is saved in tags
+func StopResources(ctx context.Context, resourceType string, accounts []dto.Account) error {
+	stopper, ok := resourceStoppers[resourceType]
+	if !ok {
+		return errors.New("unsupported resource type")
+	}
+	return forEachResource(ctx, resourceType, accounts, stopper)
+}
+
+// StartResources restores EKS clusters capacity and starts EC2 instances stopped by StopResources
+func StartResources(ctx context.Context, resourceType string, accounts []dto.Account) error {
+	starter, ok := resourceStarters[resourceType]
+	if !ok {
+		return errors.New("unsupported resource type")
+	}
+	return forEachResource(ctx, resourceType, accounts, starter)
+}
+
+func stopEKSCluster(ctx context.Context, clients *clients, resource dto.Resource) error {
+	logger := log.FromContext(ctx)
+	var errs error
+
+	logger.Info("listing cluster node groups")
+	nodeGroups, err := describeClusterNodeGroups(ctx, clients.EKS, resource.Name)
+	if err != nil {
+		return err
+	}
+	logger.Infof("scaling node groups to zero: %d", len(nodeGroups))
+	for _, nodeGroup := range nodeGroups {
+		errs = multierr.Append(errs, stopNodeGroup(ctx, clients.EKS, nodeGroup))
+	}
+
+	logger.Info("listing autoscaling groups")
+	scalingGroups, err := listSelfManagedAutoScalingGroups(ctx, clients.ASG, resource.Name)
+	if err != nil {
+		return multierr.Append(errs, err)
+	}
+	logger.Infof("scaling autoscaling groups to zero: %d", len(scalingGroups))
+	for _, scalingGroup := range scalingGroups {
+		errs = multierr.Append(errs, stopAutoScalingGroup(ctx, clients.ASG, scalingGroup))
+	}
+	return errs
+}
+
+func startEKSCluster(ctx context.Context, clients *clients, resource dto.Resource) error {
+	logger := log.FromContext(ctx)
+	var errs error
+
+	logger.Info("listing cluster node groups")
+	nodeGroups, err := describeClusterNodeGroups(ctx, clients.EKS, resource.Name)
+	if err != nil {
+		return err
+	}
+	logger.Infof("restoring node groups capacity: %d", len(nodeGroups))
+	for _, nodeGroup := range nodeGroups {
+		errs = multierr.Append(errs, startNodeGroup(ctx, clients.EKS, nodeGroup))
+	}
+
+	logger.Info("listing autoscaling groups")
+	scalingGroups, err := listSelfManagedAutoScalingGroups(ctx, clients.ASG, resource.Name)
+	if err != nil {
+		return multierr.Append(errs, err)
+	}
+	logger.Infof("restoring autoscaling groups capacity: %d", len(scalingGroups))
+	for _, scalingGroup := range scalingGroups {
+		errs = multierr.Append(errs, startAutoScalingGroup(ctx, clients.ASG, scalingGroup))
+	}
+	return errs
+}
+
+func stopNodeGroup(ctx context.Context, client *eks.Client, nodeGroup ekstypes.Nodegroup) error {
+	scaling := nodeGroup.ScalingConfig
+	if scaling == nil {
+		return nil
+	}
+	// tag is kept from the first stop, so repeated stop doesn't overwrite the original capacity with zeros
+	if _, ok := nodeGroup.Tags[originalCapacityTag]; !ok {
+		_, err := client.TagResource(ctx, &eks.TagResourceInput{
+			ResourceArn: nodeGroup.NodegroupArn,
+			Tags: map[string]string{
+				originalCapacityTag: fmt.Sprintf(originalCapacityForm,
+					aws.ToInt32(scaling.MinSize), aws.ToInt32(scaling.MaxSize), aws.ToInt32(scaling.DesiredSize)),
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if aws.ToInt32(scaling.MinSize) == 0 && aws.ToInt32(scaling.DesiredSize) == 0 {
+		return nil
+	}
+	// node group max size must be greater than zero
+	_, err := client.UpdateNodegroupConfig(ctx, &eks.UpdateNodegroupConfigInput{
+		ClusterName:   nodeGroup.ClusterName,
+		NodegroupName: nodeGroup.NodegroupName,
+		ScalingConfig: &ekstypes.NodegroupScalingConfig{
+			MinSize:     aws.Int32(0),
+			DesiredSize: aws.Int32(0),
+			MaxSize:     scaling.MaxSize,
+		},
+	})
+	return err
+}
+
+func startNodeGroup(ctx context.Context, client *eks.Client, nodeGroup ekstypes.Nodegroup) error {
+	value, ok := nodeGroup.Tags[originalCapacityTag]
+	if !ok {
+		return nil
+	}
+	var minSize, maxSize, desiredSize int32
+	if _, err := fmt.Sscanf(value, originalCapacityForm, &minSize, &maxSize, &desiredSize); err != nil {
+		return fmt.Errorf("invalid %s tag of node group %s: %w", originalCapacityTag, aws.ToString(nodeGroup.NodegroupName), err)
+	}
+	_, err := client.UpdateNodegroupConfig(ctx, &eks.UpdateNodegroupConfigInput{
+		ClusterName:   nodeGroup.ClusterName,
+		NodegroupName: nodeGroup.NodegroupName,
+		ScalingConfig: &ekstypes.NodegroupScalingConfig{
+			MinSize:     aws.Int32(minSize),
+			DesiredSize: aws.Int32(desiredSize),
+			MaxSize:     aws.Int32(maxSize),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = client.UntagResource(ctx, &eks.UntagResourceInput{
+		ResourceArn: nodeGroup.NodegroupArn,
+		TagKeys:     []string{originalCapacityTag},
+	})
+	return err
+}
+
+func stopAutoScalingGroup(ctx context.Context, client *autoscaling.Client, autoScalingGroup asgtypes.AutoScalingGroup) error {
+	if _, ok := autoScalingGroupTag(autoScalingGroup, originalCapacityTag); !ok {
+		value := fmt.Sprintf(originalCapacityForm, aws.ToInt32(autoScalingGroup.MinSize),
+			aws.ToInt32(autoScalingGroup.MaxSize), aws.ToInt32(autoScalingGroup.DesiredCapacity))
+		if err := tagAutoScalingGroup(ctx, client, autoScalingGroup, originalCapacityTag, value); err != nil {
+			return err
+		}
+	}
+	return scaleAutoScalingGroupToZero(ctx, client, autoScalingGroup)
+}
+
+func startAutoScalingGroup(ctx context.Context, client *autoscaling.Client, autoScalingGroup asgtypes.AutoScalingGroup) error {
+	value, ok := autoScalingGroupTag(autoScalingGroup, originalCapacityTag)
+	if !ok {
+		return nil
+	}
+	var minSize, maxSize, desiredSize int32
+	if _, err := fmt.Sscanf(value, originalCapacityForm, &minSize, &maxSize, &desiredSize); err != nil {
+		return fmt.Errorf("invalid %s tag of autoscaling group %s: %w", originalCapacityTag, aws.ToString(autoScalingGroup.AutoScalingGroupName), err)
+	}
+	_, err := client.UpdateAutoScalingGroup(ctx, &autoscaling.UpdateAutoScalingGroupInput{
+		AutoScalingGroupName: autoScalingGroup.AutoScalingGroupName,
+		DesiredCapacity:      aws.Int32(desiredSize),
+		MaxSize:              aws.Int32(maxSize),
+		MinSize:              aws.Int32(minSize),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = client.DeleteTags(ctx, &autoscaling.DeleteTagsInput{
+		Tags: []asgtypes.Tag{autoScalingGroupTagInput(autoScalingGroup, originalCapacityTag, value)},
+	})
+	return err
+}
+
+func stopEC2Instance(ctx context.Context, clients *clients, resource dto.Resource) error {
+	instance, err := describeInstance(ctx, clients.EC2, resource.ID)
+	if err != nil || instance == nil {
+		return err
+	}
+	if instance.State == nil || instance.State.Name != ec2types.InstanceStateNameRunning {
+		log.FromContext(ctx).Infof("instance is not running, skipping")
+		return nil
+	}
+	_, err = clients.EC2.CreateTags(ctx, &ec2.CreateTagsInput{
+		Resources: []string{resource.ID},
+		Tags: []ec2types.Tag{
+			{Key: aws.String(stoppedTag), Value: aws.String(time.Now().UTC().Format(time.RFC3339))},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = clients.EC2.StopInstances(ctx, &ec2.StopInstancesInput{
+		InstanceIds: []string{resource.ID},
+	})
+	return err
+}
+
+func startEC2Instance(ctx context.Context, clients *clients, resource dto.Resource) error {
+	instance, err := describeInstance(ctx, clients.EC2, resource.ID)
+	if err != nil || instance == nil {
+		return err
+	}
+	if _, ok := ec2Tag(instance.Tags, stoppedTag); !ok {
+		log.FromContext(ctx).Infof("instance was not stopped by c7n-helper, skipping")
+		return nil
+	}
+	if instance.State != nil && instance.State.Name == ec2types.InstanceStateNameStopped {
+		_, err = clients.EC2.StartInstances(ctx, &ec2.StartInstancesInput{
+			InstanceIds: []string{resource.ID},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	_, err = clients.EC2.DeleteTags(ctx, &ec2.DeleteTagsInput{
+		Resources: []string{resource.ID},
+		Tags:      []ec2types.Tag{{Key: aws.String(stoppedTag)}},
+	})
+	return err
+}
+
+// Returns nil if the instance is not found
+func describeInstance(ctx context.Context, client *ec2.Client, instanceId string) (*ec2types.Instance, error) {
+	if instanceId == "" {
+		return nil, errors.New("instance ID is missing in the resource file, please re-run parse")
+	}
+	output, err := client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
+		Filters: []ec2types.Filter{
+			{
+				Name:   aws.String("instance-id"),
+				Values: []string{instanceId},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, reservation := range output.Reservations {
+		if len(reservation.Instances) > 0 {
+			return &reservation.Instances[0], nil
+		}
+	}
+	log.FromContext(ctx).Info("instance not found, skipping")
+	return nil, nil
+}
+
+func describeClusterNodeGroups(ctx context.Context, client *eks.Client, clusterName string) ([]ekstypes.Nodegroup, error) {
+	names, err := listClusterNodeGroups(ctx, client, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	nodeGroups := make([]ekstypes.Nodegroup, 0, len(names))
+	for _, name := range names {
+		output, err := client.DescribeNodegroup(ctx, &eks.DescribeNodegroupInput{
+			ClusterName:   aws.String(clusterName),
+			NodegroupName: aws.String(name),
+		})
+		if err != nil {
+			return nil, err
+		}
+		nodeGroups = append(nodeGroups, *output.Nodegroup)
+	}
+	return nodeGroups, nil
+}
+
+// Returns cluster autoscaling groups except EKS managed node groups ones
+func listSelfManagedAutoScalingGroups(ctx context.Context, client *autoscaling.Client, clusterName string) ([]asgtypes.AutoScalingGroup, error) {
+	groups, err := listAutoScalingGroups(ctx, client, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]asgtypes.AutoScalingGroup, 0, len(groups))
+	for _, group := range groups {
+		if _, ok := autoScalingGroupTag(group, eksNodeGroupNameTag); !ok {
+			result = append(result, group)
+		}
+	}
+	return result, nil
+}
+
+func autoScalingGroupTag(autoScalingGroup asgtypes.AutoScalingGroup, key string) (string, bool) {
+	for _, tag := range autoScalingGroup.Tags {
+		if aws.ToString(tag.Key) == key {
+			return aws.ToString(tag.Value), true
+		}
+	}
+	return "", false
+}
+
+func tagAutoScalingGroup(ctx context.Context, client *autoscaling.Client, autoScalingGroup asgtypes.AutoScalingGroup, key, value string) error {
+	_, err := client.CreateOrUpdateTags(ctx, &autoscaling.CreateOrUpdateTagsInput{
+		Tags: []asgtypes.Tag{autoScalingGroupTagInput(autoScalingGroup, key, value)},
+	})
+	return err
+}
+
+func autoScalingGroupTagInput(autoScalingGroup asgtypes.AutoScalingGroup, key, value string) asgtypes.Tag {
+	return asgtypes.Tag{
+		Key:               aws.String(key),
+		Value:             aws.String(value),
+		ResourceId:        autoScalingGroup.AutoScalingGroupName,
+		ResourceType:      aws.String("auto-scaling-group"),
+		PropagateAtLaunch: aws.Bool(false),
+	}
+}
@@ -1,6 +1,124 @@
 package aws
 
+import (
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+const (
+	kubernetesClusterTagPrefix = "kubernetes.io/cluster/"
+	kubernetesClusterTag       = "KubernetesCluster"
+	eksClusterNameTag          = "eks:cluster-name"
+)
+
+// Tags with cluster name as a value
+var clusterNameTags = []string{
+	kubernetesClusterTag,
+	eksClusterNameTag,
+	"alpha.eksctl.io/cluster-name",
+	"eksctl.cluster.k8s.io/v1alpha1/cluster-name",
+	"elbv2.k8s.aws/cluster",
+	"karpenter.sh/discovery",
+	"karpenter.k8s.aws/cluster",
+}
+
+// Tags set only on EKS clusters resources: by EKS, managed node groups and eksctl
+var eksClusterNameTags = map[string]struct{}{
+	eksClusterNameTag:                             {},
+	"aws:eks:cluster-name":                        {},
+	"alpha.eksctl.io/cluster-name":                {},
+	"eksctl.cluster.k8s.io/v1alpha1/cluster-name": {},
+}
+
+// Returns sorted tag keys
+func tagKeys(tags map[string]struct{}) []string {
+	result := make([]string, 0, len(tags))
+	for key := range tags {
+		result = append(result, key)
+	}
+	sort.Strings(result)
+	return result
+}
+
 type keyValue struct {
 	Key   string `json:"Key"`
 	Value string `json:"Value"`
 }
+
+// Returns tags map, keys of AWS tags are unique
+func tagMap(tags []keyValue) map[string]string {
+	result := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		result[tag.Key] = tag.Value
+	}
+	return result
+}
+
+// Returns cluster name from `kubernetes.io/cluster/<name>` or `KubernetesCluster` tag
+func kubernetesClusterName(tags []keyValue) string {
+	for _, tag := range tags {
+		if tag.Key == kubernetesClusterTag && tag.Value != "" {
+			return tag.Value
+		}
+		if name, ok := strings.CutPrefix(tag.Key, kubernetesClusterTagPrefix); ok && name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// Returns cluster name from any known cluster tag
+func clusterNameFromTag(key, value string) string {
+	if name, ok := strings.CutPrefix(key, kubernetesClusterTagPrefix); ok {
+		return name
+	}
+	for _, tag := range clusterNameTags {
+		if key == tag {
+			return value
+		}
+	}
+	return ""
+}
+
+func ec2Tag(tags []types.Tag, key string) (string, bool) {
+	for _, tag := range tags {
+		if aws.ToString(tag.Key) == key {
+			return aws.ToString(tag.Value), true
+		}
+	}
+	return "", false
+}
+
+// Returns the first EC2 tag that marks the resource as belonging to the cluster
+func clusterTag(tags []types.Tag, clusterName string) (types.Tag, bool) {
+	for _, tag := range tags {
+		if clusterNameFromTag(aws.ToString(tag.Key), aws.ToString(tag.Value)) == clusterName {
+			return tag, true
+		}
+	}
+	return types.Tag{}, false
+}
+
+// Returns EC2 filter sets (each set is applied in a separate request) matching cluster tags
+func ec2ClusterFilters(clusterName string) [][]types.Filter {
+	filters := [][]types.Filter{
+		{
+			{
+				Name:   aws.String("tag-key"),
+				Values: []string{kubernetesClusterTagPrefix + clusterName},
+			},
+		},
+	}
+	for _, tag := range clusterNameTags {
+		filters = append(filters, []types.Filter{
+			{
+				Name:   aws.String("tag:" + tag),
+				Values: []string{clusterName},
+			},
+		})
+	}
+	return filters
+}
@@ -1,37 +1,41 @@
 package azure
 
 import (
-	"encoding/json"
+	"io"
 	"time"
 
 	"c7n-helper/pkg/date"
 	"c7n-helper/pkg/dto"
+	"c7n-helper/pkg/expiry"
+	"c7n-helper/pkg/jsonstream"
+	"c7n-helper/pkg/tagschema"
 )
 
-type tags struct {
-	Owner   string `json:"owner"`
-	Created string `json:"created"`
-	Expiry  string `json:"expiry"`
+type resourceGroup struct {
+	Name     string            `json:"name"`
+	Location string            `json:"location"`
+	Tags     map[string]string `json:"tags"`
 }
 
-func RG(_ string, content []byte) ([]dto.Resource, error) {
-	var groups []struct {
-		Name     string `json:"name"`
-		Location string `json:"location"`
-		Tags     tags   `json:"tags"`
-	}
-	if err := json.Unmarshal(content, &groups); err != nil {
-		return nil, err
-	}
-	result := make([]dto.Resource, 0, len(groups))
-	for _, group := range groups {
+func RG(_ string, r io.Reader, schema tagschema.Config) ([]dto.Resource, error) {
+	result := make([]dto.Resource, 0)
+	err := jsonstream.DecodeArray(r, func(group resourceGroup) error {
+		values := schema.Azure.Resolve(group.Tags)
+		// unknown creation time stays zero, so default TTL and ttl= expiry are not applied to it
+		created := date.ParseOrDefault(values.Created, time.Time{})
+		expiresAt, expiryStatus := expiry.Resolve(values.Expiry, created, time.Now())
 		result = append(result, dto.Resource{
-			Name:     group.Name,
-			Location: group.Location,
-			Owner:    group.Tags.Owner,
-			Created:  date.ParseOrDefault(group.Tags.Created, time.Now()),
-			Expiry:   date.ParseOrDefault(group.Tags.Expiry, time.Now()),
+			Name:         group.Name,
+			Location:     group.Location,
+			Owner:        values.Owner,
+			Team:         values.Team,
+			Created:      created,
+			Expiry:       expiresAt,
+			ExpiryStatus: expiryStatus,
+			DeleteAfter:  date.ParseOrDefault(group.Tags[deleteAfterTag], time.Time{}),
+			Sources:      values.Sources,
 		})
-	}
-	return result, nil
+		return nil
+	})
+	return result, err
 }
@@ -0,0 +1,60 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+
+	"c7n-helper/pkg/dto"
+	"c7n-helper/pkg/log"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"go.uber.org/multierr"
+)
+
+// Scheduled deletion date tag, Azure tag names can't contain slashes
+const deleteAfterTag = "c7n-helper-delete-after"
+
+// MarkRG tags resource groups with the scheduled deletion date, already marked groups are not changed.
+// Default Azure credentials are used, account name is subscription ID.
+func MarkRG(ctx context.Context, accounts []dto.Account, deleteAfter string) (errs error) {
+	credential, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return err
+	}
+	for _, account := range accounts {
+		client, err := armresources.NewTagsClient(account.Name, credential, nil)
+		if err != nil {
+			errs = multierr.Append(errs, err)
+			continue
+		}
+		for _, resource := range account.Resources {
+			ctx, _ := log.UpdateContext(ctx, "subscription", account.Name, "arg", resource.Name)
+			scope := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s", account.Name, resource.Name)
+			errs = multierr.Append(errs, markGroup(ctx, client, scope, deleteAfter))
+		}
+	}
+	return
+}
+
+func markGroup(ctx context.Context, client *armresources.TagsClient, scope, deleteAfter string) error {
+	logger := log.FromContext(ctx)
+	current, err := client.GetAtScope(ctx, scope, nil)
+	if err != nil {
+		return err
+	}
+	if current.Properties != nil {
+		if value, ok := current.Properties.Tags[deleteAfterTag]; ok && value != nil {
+			logger.Infof("already marked for deletion after %s", *value)
+			return nil
+		}
+	}
+	logger.Infof("marking for deletion after %s", deleteAfter)
+	_, err = client.UpdateAtScope(ctx, scope, armresources.TagsPatchResource{
+		Operation: to.Ptr(armresources.TagsPatchOperationMerge),
+		Properties: &armresources.Tags{
+			Tags: map[string]*string{deleteAfterTag: to.Ptr(deleteAfter)},
+		},
+	}, nil)
+	return err
+}
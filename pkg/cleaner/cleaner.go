@@ -6,29 +6,100 @@ import (
 	"strings"
 	"time"
 
+	"c7n-helper/pkg/approval"
 	"c7n-helper/pkg/aws"
 	"c7n-helper/pkg/dto"
+	"c7n-helper/pkg/expiry"
 	"c7n-helper/pkg/log"
+	"go.uber.org/multierr"
 )
 
-func Clean(ctx context.Context, resourceFile string, tries int, retryInterval time.Duration) error {
+// Clean deletes resources from the resource file sections of supported types, only approved ones if the approval is set
+func Clean(ctx context.Context, resourceFile string, verification dto.Verification, approved *approval.Approval, opts aws.DeleteOptions) error {
 	logger := log.FromContext(ctx)
 	logger.Info("reading resource file...")
-	var report dto.PolicyReport
+	var report dto.Report
 	if err := report.ReadFromFile(resourceFile); err != nil {
 		return err
 	}
-	if strings.ToLower(report.Type) != "eks" {
-		return errors.New("unsupported resource type")
-	}
-	logger.Info("preparing aws clients...")
-	if err := aws.InitClientsMap(ctx, report.Accounts); err != nil {
+	logger.Info("verifying resource file...")
+	if err := report.Verify(verification); err != nil {
 		return err
 	}
-	logger.Info("starting resources cleanup...")
-	if err := aws.DeleteResources(ctx, report.Accounts, tries, retryInterval); err != nil {
+	sections, err := supportedSections(ctx, report, aws.IsDeletable)
+	if err != nil {
 		return err
 	}
+	if approved != nil {
+		if err := approved.Check(); err != nil {
+			return err
+		}
+		logger.Infof("using approval by %s valid until %s", approved.Approver, approved.Expires.Format(time.RFC3339))
+		for i := range sections {
+			sections[i].Accounts = approved.Filter(sections[i])
+		}
+	}
+	for i := range sections {
+		sections[i].Accounts = expiry.SkipNever(sections[i].Accounts, warnNeverExpiring(ctx))
+	}
+	for _, section := range sections {
+		if err := aws.CheckRequireMark(section.Type, opts); err != nil {
+			return err
+		}
+	}
+	if opts.KMSKeys && (opts.KMSPendingWindow < 7 || opts.KMSPendingWindow > 30) {
+		return errors.New("kms pending window must be between 7 and 30 days")
+	}
+	if opts.LeaseDuration > 0 && opts.LeaseDuration < time.Minute {
+		return errors.New("lease duration must be at least 1 minute")
+	}
+	if opts.LeaseDuration > 0 && (opts.RunID == "" || strings.ContainsAny(opts.RunID, " \t\n")) {
+		return errors.New("run id must be non-empty and without whitespaces")
+	}
+	if opts.Window != nil {
+		logger.Info("checking maintenance window...")
+		if err := opts.Window.Check(time.Now()); err != nil {
+			return err
+		}
+	}
+	logger.Info("preparing aws clients...")
+	for _, section := range sections {
+		if err := aws.InitClientsMap(ctx, section.Accounts); err != nil {
+			return err
+		}
+	}
+	var errs error
+	for _, section := range sections {
+		logger.Infof("starting %s resources cleanup of %s policy...", section.Type, section.Policy)
+		errs = multierr.Append(errs, aws.DeleteResources(ctx, section.Type, section.Accounts, opts))
+	}
+	if errs != nil {
+		return errs
+	}
 	logger.Info("finished successful")
 	return nil
 }
+
+// Logs resources skipped because they never expire
+func warnNeverExpiring(ctx context.Context) func(account string, resource dto.Resource) {
+	return func(account string, resource dto.Resource) {
+		log.FromContext(ctx).Warnf("skipping %s/%s/%s: resource never expires", account, resource.Location, resource.Name)
+	}
+}
+
+// Returns report sections of the supported types with lower case types, fails if there are no supported sections
+func supportedSections(ctx context.Context, report dto.Report, isSupported func(resourceType string) bool) ([]dto.PolicyReport, error) {
+	sections := make([]dto.PolicyReport, 0, len(report.Sections))
+	for _, section := range report.Sections {
+		section.Type = strings.ToLower(section.Type)
+		if !isSupported(section.Type) {
+			log.FromContext(ctx).Infof("skipping unsupported %s section of %s policy", section.Type, section.Policy)
+			continue
+		}
+		sections = append(sections, section)
+	}
+	if len(sections) == 0 {
+		return nil, errors.New("unsupported resource type")
+	}
+	return sections, nil
+}
@@ -0,0 +1,45 @@
+package cleaner
+
+import (
+	"context"
+
+	"c7n-helper/pkg/aws"
+	"c7n-helper/pkg/expiry"
+	"c7n-helper/pkg/log"
+	"go.uber.org/multierr"
+)
+
+func Quarantine(ctx context.Context, resourceFile string, publicAccessCidrs []string) error {
+	sections, err := readSections(ctx, resourceFile, aws.IsQuarantinable)
+	if err != nil {
+		return err
+	}
+	log.FromContext(ctx).Info("quarantining resources...")
+	var errs error
+	for _, section := range sections {
+		accounts := expiry.SkipNever(section.Accounts, warnNeverExpiring(ctx))
+		errs = multierr.Append(errs, aws.QuarantineResources(ctx, section.Type, accounts, publicAccessCidrs))
+	}
+	if errs != nil {
+		return errs
+	}
+	log.FromContext(ctx).Info("finished successful")
+	return nil
+}
+
+func Unquarantine(ctx context.Context, resourceFile string) error {
+	sections, err := readSections(ctx, resourceFile, aws.IsQuarantinable)
+	if err != nil {
+		return err
+	}
+	log.FromContext(ctx).Info("unquarantining resources...")
+	var errs error
+	for _, section := range sections {
+		errs = multierr.Append(errs, aws.UnquarantineResources(ctx, section.Type, section.Accounts))
+	}
+	if errs != nil {
+		return errs
+	}
+	log.FromContext(ctx).Info("finished successful")
+	return nil
+}
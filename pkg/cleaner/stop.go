@@ -0,0 +1,67 @@
+package cleaner
+
+import (
+	"context"
+
+	"c7n-helper/pkg/aws"
+	"c7n-helper/pkg/dto"
+	"c7n-helper/pkg/expiry"
+	"c7n-helper/pkg/log"
+	"go.uber.org/multierr"
+)
+
+func Stop(ctx context.Context, resourceFile string) error {
+	sections, err := readSections(ctx, resourceFile, aws.IsStoppable)
+	if err != nil {
+		return err
+	}
+	log.FromContext(ctx).Info("stopping resources...")
+	var errs error
+	for _, section := range sections {
+		accounts := expiry.SkipNever(section.Accounts, warnNeverExpiring(ctx))
+		errs = multierr.Append(errs, aws.StopResources(ctx, section.Type, accounts))
+	}
+	if errs != nil {
+		return errs
+	}
+	log.FromContext(ctx).Info("finished successful")
+	return nil
+}
+
+func Start(ctx context.Context, resourceFile string) error {
+	sections, err := readSections(ctx, resourceFile, aws.IsStoppable)
+	if err != nil {
+		return err
+	}
+	log.FromContext(ctx).Info("starting resources...")
+	var errs error
+	for _, section := range sections {
+		errs = multierr.Append(errs, aws.StartResources(ctx, section.Type, section.Accounts))
+	}
+	if errs != nil {
+		return errs
+	}
+	log.FromContext(ctx).Info("finished successful")
+	return nil
+}
+
+// Reads the resource file sections of the supported types and prepares aws clients for them
+func readSections(ctx context.Context, resourceFile string, isSupported func(resourceType string) bool) ([]dto.PolicyReport, error) {
+	logger := log.FromContext(ctx)
+	logger.Info("reading resource file...")
+	var report dto.Report
+	if err := report.ReadFromFile(resourceFile); err != nil {
+		return nil, err
+	}
+	sections, err := supportedSections(ctx, report, isSupported)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("preparing aws clients...")
+	for _, section := range sections {
+		if err := aws.InitClientsMap(ctx, section.Accounts); err != nil {
+			return nil, err
+		}
+	}
+	return sections, nil
+}
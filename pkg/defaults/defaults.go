@@ -0,0 +1,78 @@
+package defaults
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"c7n-helper/pkg/expiry"
+	"gopkg.in/yaml.v3"
+)
+
+// Policy is YAML file content: default TTL rules of resources without expiry tag, the first matching rule is used
+type Policy struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule matches resources by type, account and owner presence, empty fields match any resource
+type Rule struct {
+	Type    string `yaml:"type"`
+	Account string `yaml:"account"`
+	// Matches resources with (true) or without (false) owner
+	Owner *bool `yaml:"owner"`
+	// Go duration, days (`3d`) or weeks (`2w`) after the resource creation
+	TTL string `yaml:"ttl"`
+
+	ttl time.Duration
+}
+
+// Default returns 10 days TTL of GKE clusters and GCE instances, other resources expire at the parse time
+func Default() Policy {
+	return Policy{Rules: []Rule{
+		{Type: "gke", TTL: "10d", ttl: 10 * 24 * time.Hour},
+		{Type: "gce", TTL: "10d", ttl: 10 * 24 * time.Hour},
+	}}
+}
+
+// Load reads default TTL policy YAML file, returns Default if the file is empty.
+// Default rules are appended after the file rules, so GKE clusters and GCE instances keep the default TTL
+// unless a file rule matches them.
+func Load(file string) (Policy, error) {
+	if file == "" {
+		return Default(), nil
+	}
+	var policy Policy
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return policy, err
+	}
+	if err := yaml.Unmarshal(content, &policy); err != nil {
+		return policy, err
+	}
+	for i := range policy.Rules {
+		ttl, err := expiry.ParseDuration(policy.Rules[i].TTL)
+		if err != nil {
+			return policy, fmt.Errorf("rule %d: %w", i+1, err)
+		}
+		policy.Rules[i].ttl = ttl
+	}
+	policy.Rules = append(policy.Rules, Default().Rules...)
+	return policy, nil
+}
+
+// TTL returns default TTL of the first matching rule, false if no rule matches
+func (p Policy) TTL(resourceType, account string, hasOwner bool) (time.Duration, bool) {
+	for _, rule := range p.Rules {
+		if rule.Type != "" && rule.Type != resourceType {
+			continue
+		}
+		if rule.Account != "" && rule.Account != account {
+			continue
+		}
+		if rule.Owner != nil && *rule.Owner != hasOwner {
+			continue
+		}
+		return rule.ttl, true
+	}
+	return 0, false
+}
@@ -0,0 +1,54 @@
+package defaults_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"c7n-helper/pkg/defaults"
+	"github.com/stretchr/testify/assert"
+)
+
+const policy = `
+rules:
+  - type: ec2
+    owner: false
+    ttl: 3d
+  - type: ec2
+    account: sandbox
+    ttl: 36h
+  - type: gke
+    ttl: 2w
+`
+
+func TestTTL(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "defaults.yaml")
+	assert.NoError(t, os.WriteFile(file, []byte(policy), 0644))
+	p, err := defaults.Load(file)
+	assert.NoError(t, err)
+
+	ttl, ok := p.TTL("ec2", "dev", false)
+	assert.True(t, ok)
+	assert.Equal(t, 72*time.Hour, ttl)
+	ttl, ok = p.TTL("ec2", "sandbox", true)
+	assert.True(t, ok)
+	assert.Equal(t, 36*time.Hour, ttl)
+	_, ok = p.TTL("ec2", "dev", true)
+	assert.False(t, ok)
+	ttl, _ = p.TTL("gke", "dev", true)
+	assert.Equal(t, 14*24*time.Hour, ttl)
+
+	// built-in rules follow the file rules
+	ttl, ok = p.TTL("gce", "dev", false)
+	assert.True(t, ok)
+	assert.Equal(t, 10*24*time.Hour, ttl)
+
+	ttl, ok = defaults.Default().TTL("gce", "dev", false)
+	assert.True(t, ok)
+	assert.Equal(t, 10*24*time.Hour, ttl)
+
+	assert.NoError(t, os.WriteFile(file, []byte("rules:\n  - type: ec2\n    ttl: soon\n"), 0644))
+	_, err = defaults.Load(file)
+	assert.Error(t, err)
+}
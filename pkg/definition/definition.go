@@ -0,0 +1,184 @@
+package definition
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"c7n-helper/pkg/date"
+	"c7n-helper/pkg/dto"
+	"c7n-helper/pkg/expiry"
+	"c7n-helper/pkg/jsonstream"
+	"c7n-helper/pkg/tagschema"
+	"gopkg.in/yaml.v3"
+)
+
+// Definitions is YAML file content: resource type name -> definition
+type Definitions struct {
+	Types map[string]Definition `yaml:"types"`
+}
+
+// Definition describes how to read resources from C7N resources.json, fields are dot-separated JSON paths
+type Definition struct {
+	// C7N policy resource, e.g. `aws.rds`, used to infer the type from metadata.json
+	Resource string `yaml:"resource"`
+	Name     string `yaml:"name"`
+	ID       string `yaml:"id"`
+	// Region from the report path is used if empty
+	Location string `yaml:"location"`
+	// RFC3339, `2006-01-02`, `2006-01-02 15:04:05` string or unix seconds number
+	Created string `yaml:"created"`
+	Tags    Tags   `yaml:"tags"`
+	// Tag keys (case-insensitive) of resource owner and expiry date, the first found is used.
+	// Tag schema keys of the resource cloud are used after them.
+	Owner  []string `yaml:"owner"`
+	Expiry []string `yaml:"expiry"`
+	// Expiry is created time plus TTL if expiry tag is missing, current time if TTL is not set.
+	// Go duration, days (`7d`) or weeks (`2w`).
+	TTL string `yaml:"ttl"`
+
+	ttl time.Duration
+}
+
+// Tags describes resource tags: list of key-value objects (AWS) or object (GCP labels, Azure tags) if key is empty
+type Tags struct {
+	Path  string `yaml:"path"`
+	Key   string `yaml:"key"`
+	Value string `yaml:"value"`
+}
+
+// Load reads definitions YAML file
+func Load(file string) (Definitions, error) {
+	var definitions Definitions
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return definitions, err
+	}
+	if err := yaml.Unmarshal(content, &definitions); err != nil {
+		return definitions, err
+	}
+	for name, d := range definitions.Types {
+		if d.Name == "" {
+			return definitions, fmt.Errorf("definition %s: name path is required", name)
+		}
+		if d.Tags.Key != "" && d.Tags.Value == "" {
+			return definitions, fmt.Errorf("definition %s: tags value field is required with key field", name)
+		}
+		if d.TTL != "" {
+			ttl, err := expiry.ParseDuration(d.TTL)
+			if err != nil {
+				return definitions, fmt.Errorf("definition %s: %w", name, err)
+			}
+			d.ttl = ttl
+			definitions.Types[name] = d
+		}
+	}
+	return definitions, nil
+}
+
+// Parse reads resources from C7N report content, tags are resolved by the schema of the resource cloud
+// with the definition owner and expiry keys first
+func (d Definition) Parse(region string, r io.Reader, config tagschema.Config) ([]dto.Resource, error) {
+	schema := config.ForResource(d.Resource)
+	schema.Owner = append(append([]string{}, d.Owner...), schema.Owner...)
+	schema.Expiry = append(append([]string{}, d.Expiry...), schema.Expiry...)
+	result := make([]dto.Resource, 0)
+	err := jsonstream.DecodeArray(r, func(item map[string]interface{}) error {
+		values := schema.Resolve(d.tags(item))
+		created := parseTime(lookup(item, d.Created))
+		if created.IsZero() {
+			created = date.ParseOrDefault(values.Created, time.Time{})
+		}
+		now := time.Now()
+		defaultExpiry := now
+		if d.ttl > 0 && !created.IsZero() {
+			defaultExpiry = created.Add(d.ttl)
+		}
+		expiresAt, expiryStatus := expiry.Resolve(values.Expiry, created, defaultExpiry)
+		if expiryStatus == expiry.Missing && !defaultExpiry.Equal(now) {
+			expiryStatus = expiry.Defaulted
+		}
+		location := region
+		if d.Location != "" {
+			location = stringValue(lookup(item, d.Location))
+		}
+		result = append(result, dto.Resource{
+			ID:           stringValue(lookup(item, d.ID)),
+			Name:         stringValue(lookup(item, d.Name)),
+			Location:     location,
+			Owner:        values.Owner,
+			Team:         values.Team,
+			Created:      created,
+			Expiry:       expiresAt,
+			ExpiryStatus: expiryStatus,
+			Sources:      values.Sources,
+		})
+		return nil
+	})
+	return result, err
+}
+
+// Returns tags with the original keys, the keys are matched case-insensitively by the tag schema
+func (d Definition) tags(item map[string]interface{}) map[string]string {
+	tags := make(map[string]string)
+	if d.Tags.Path == "" {
+		return tags
+	}
+	switch value := lookup(item, d.Tags.Path).(type) {
+	case []interface{}:
+		for _, entry := range value {
+			if tag, ok := entry.(map[string]interface{}); ok {
+				tags[stringValue(lookup(tag, d.Tags.Key))] = stringValue(lookup(tag, d.Tags.Value))
+			}
+		}
+	case map[string]interface{}:
+		for k, v := range value {
+			tags[k] = stringValue(v)
+		}
+	}
+	return tags
+}
+
+// Returns nil if the path is empty or not found
+func lookup(item map[string]interface{}, path string) interface{} {
+	if path == "" {
+		return nil
+	}
+	var current interface{} = item
+	for _, field := range strings.Split(path, ".") {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		if current, ok = object[field]; !ok {
+			return nil
+		}
+	}
+	return current
+}
+
+func stringValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func parseTime(value interface{}) time.Time {
+	switch v := value.(type) {
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t
+		}
+		return date.ParseOrDefault(v, time.Time{})
+	case float64:
+		return time.Unix(int64(v), 0).UTC()
+	}
+	return time.Time{}
+}
@@ -0,0 +1,107 @@
+package definition_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"c7n-helper/pkg/definition"
+	"c7n-helper/pkg/tagschema"
+	"github.com/stretchr/testify/assert"
+)
+
+const definitions = `
+types:
+  rds:
+    name: DBInstanceIdentifier
+    id: DbiResourceId
+    created: InstanceCreateTime
+    tags:
+      path: Tags
+      key: Key
+      value: Value
+    owner: [owner, created-by]
+    expiry: [expiry]
+    ttl: 7d
+  cloudsql:
+    resource: gcp.sql-instance
+    name: name
+    location: region
+    created: createTime
+    tags:
+      path: settings.userLabels
+    owner: [owner]
+`
+
+func TestParse(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "definitions.yaml")
+	assert.NoError(t, os.WriteFile(file, []byte(definitions), 0644))
+	defs, err := definition.Load(file)
+	assert.NoError(t, err)
+	assert.Len(t, defs.Types, 2)
+
+	resources, err := defs.Types["rds"].Parse("us-east-1", strings.NewReader(`[
+		{"DBInstanceIdentifier": "db-1", "DbiResourceId": "db-ABC", "InstanceCreateTime": "2024-05-01T10:00:00Z",
+		 "Tags": [{"Key": "Created-By", "Value": "alice"}, {"Key": "expiry", "Value": "2024-06-01"}]},
+		{"DBInstanceIdentifier": "db-2", "InstanceCreateTime": "2024-05-01T10:00:00Z"}
+	]`), tagschema.Default())
+	assert.NoError(t, err)
+	assert.Len(t, resources, 2)
+	assert.Equal(t, "db-1", resources[0].Name)
+	assert.Equal(t, "db-ABC", resources[0].ID)
+	assert.Equal(t, "us-east-1", resources[0].Location)
+	assert.Equal(t, "alice", resources[0].Owner)
+	assert.Equal(t, map[string]string{"owner": "Created-By", "expiry": "expiry"}, resources[0].Sources)
+	assert.Equal(t, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), resources[0].Created)
+	assert.Equal(t, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), resources[0].Expiry)
+	assert.Equal(t, time.Date(2024, 5, 8, 10, 0, 0, 0, time.UTC), resources[1].Expiry)
+
+	resources, err = defs.Types["cloudsql"].Parse("global", strings.NewReader(`[
+		{"name": "sql-1", "region": "europe-west1", "createTime": "2024-05-01T10:00:00.123Z", "settings": {"userLabels": {"owner": "bob"}}}
+	]`), tagschema.Default())
+	assert.NoError(t, err)
+	assert.Len(t, resources, 1)
+	assert.Equal(t, "europe-west1", resources[0].Location)
+	assert.Equal(t, "bob", resources[0].Owner)
+}
+
+func TestParseTagSchema(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "definitions.yaml")
+	assert.NoError(t, os.WriteFile(file, []byte(definitions), 0644))
+	defs, err := definition.Load(file)
+	assert.NoError(t, err)
+	schemaFile := filepath.Join(t.TempDir(), "tag-schema.yaml")
+	assert.NoError(t, os.WriteFile(schemaFile, []byte("aws:\n  owner: [Contact]\n  expiry: [Expires-On]\ngcp:\n  team: [squad]\n"), 0644))
+	schema, err := tagschema.Load(schemaFile)
+	assert.NoError(t, err)
+
+	resources, err := defs.Types["rds"].Parse("us-east-1", strings.NewReader(`[
+		{"DBInstanceIdentifier": "db-1", "Tags": [{"Key": "CONTACT", "Value": "alice"}, {"Key": "expires-on", "Value": "2024-06-01"}]},
+		{"DBInstanceIdentifier": "db-2", "Tags": [{"Key": "owner", "Value": "bob"}, {"Key": "Contact", "Value": "alice"}]}
+	]`), schema)
+	assert.NoError(t, err)
+	// aliases of the tag schema are used after the definition keys, sources record the matched tag keys
+	assert.Equal(t, "alice", resources[0].Owner)
+	assert.Equal(t, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), resources[0].Expiry)
+	assert.Equal(t, map[string]string{"owner": "CONTACT", "expiry": "expires-on"}, resources[0].Sources)
+	assert.Equal(t, "bob", resources[1].Owner)
+
+	resources, err = defs.Types["cloudsql"].Parse("global", strings.NewReader(`[
+		{"name": "sql-1", "settings": {"userLabels": {"squad": "data"}}}
+	]`), schema)
+	assert.NoError(t, err)
+	assert.Equal(t, "data", resources[0].Team)
+}
+
+func TestLoadInvalid(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "definitions.yaml")
+	assert.NoError(t, os.WriteFile(file, []byte("types:\n  rds:\n    id: DbiResourceId\n"), 0644))
+	_, err := definition.Load(file)
+	assert.Error(t, err)
+
+	assert.NoError(t, os.WriteFile(file, []byte("types:\n  rds:\n    name: DBInstanceIdentifier\n    ttl: soon\n"), 0644))
+	_, err = definition.Load(file)
+	assert.Error(t, err)
+}
@@ -11,6 +11,49 @@ type PolicyReport struct {
 	Type     string    `json:"type"`
 	Policy   string    `json:"policy"`
 	Accounts []Account `json:"accounts"`
+	// C7N runs of the policy per account and region
+	Runs []Run `json:"runs,omitempty"`
+	// C7N resource files that failed to parse in record error mode
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+	// Set by parse command if the report is signed
+	Generated *time.Time `json:"generated,omitempty"`
+	Signature string     `json:"signature,omitempty"`
+	// JSON content the report was read from, the signature is verified against it
+	raw json.RawMessage
+}
+
+// UnmarshalJSON keeps the read content for signature verification
+func (r *PolicyReport) UnmarshalJSON(data []byte) error {
+	type plain PolicyReport
+	if err := json.Unmarshal(data, (*plain)(r)); err != nil {
+		return err
+	}
+	r.raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// Run is C7N policy execution in the account and region from metadata.json and custodian-run.log
+type Run struct {
+	Account         string    `json:"account"`
+	Region          string    `json:"region"`
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	DurationSeconds float64   `json:"durationSeconds"`
+	ResourceCount   int       `json:"resourceCount"`
+	APICalls        int       `json:"apiCalls"`
+	// Number of error lines in custodian-run.log and the first of them
+	Errors        int      `json:"errors"`
+	ErrorMessages []string `json:"errorMessages,omitempty"`
+	// Reason of the failed run, empty if the run succeeded
+	Failure string `json:"failure,omitempty"`
+}
+
+// Diagnostic is C7N resource file that failed to parse
+type Diagnostic struct {
+	Account string `json:"account"`
+	Region  string `json:"region"`
+	File    string `json:"file"`
+	Error   string `json:"error"`
 }
 
 type Account struct {
@@ -19,11 +62,19 @@ type Account struct {
 }
 
 type Resource struct {
+	ID       string    `json:"id,omitempty"`
 	Name     string    `json:"name"`
 	Location string    `json:"location"`
 	Owner    string    `json:"owner"`
+	Team     string    `json:"team,omitempty"`
 	Created  time.Time `json:"created"`
 	Expiry   time.Time `json:"expiry"`
+	// Empty if the expiry is read from the tag, otherwise missing, never or invalid
+	ExpiryStatus string `json:"expiryStatus,omitempty"`
+	// Scheduled deletion date written by mark command, zero if the resource is not marked
+	DeleteAfter time.Time `json:"deleteAfter"`
+	// Resource field (owner, expiry, created, name, team) -> tag key of its value
+	Sources map[string]string `json:"sources,omitempty"`
 }
 
 func (r *PolicyReport) ReadFromFile(reportFile string) error {
@@ -37,6 +88,64 @@ func (r *PolicyReport) ReadFromFile(reportFile string) error {
 	return nil
 }
 
+func (r *PolicyReport) WriteToFile(reportFile string) error {
+	file, err := json.MarshalIndent(r, "", " ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(reportFile, file, 0644)
+}
+
 func (r *PolicyReport) String() string {
 	return fmt.Sprintf("%s report with %d accounts", r.Type, len(r.Accounts))
 }
+
+// Report is the resource file with many policy sections of different resource types
+type Report struct {
+	Sections []PolicyReport `json:"sections"`
+}
+
+// ReadFromFile reads multi-section report, single policy report is read as one section
+func (r *Report) ReadFromFile(reportFile string) error {
+	file, err := os.ReadFile(reportFile)
+	if err != nil {
+		return err
+	}
+	var probe struct {
+		Sections json.RawMessage `json:"sections"`
+	}
+	if err := json.Unmarshal(file, &probe); err != nil {
+		return err
+	}
+	if probe.Sections != nil {
+		return json.Unmarshal(file, r)
+	}
+	var single PolicyReport
+	if err := json.Unmarshal(file, &single); err != nil {
+		return err
+	}
+	r.Sections = []PolicyReport{single}
+	return nil
+}
+
+func (r *Report) WriteToFile(reportFile string) error {
+	file, err := json.MarshalIndent(r, "", " ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(reportFile, file, 0644)
+}
+
+// Verify checks each section, see PolicyReport.Verify
+func (r *Report) Verify(v Verification) error {
+	for _, section := range r.Sections {
+		if err := section.Verify(v); err != nil {
+			return fmt.Errorf("%s section: %w", section.Policy, err)
+		}
+	}
+	return nil
+}
+
+func (r *Report) String() string {
+	return fmt.Sprintf("report with %d sections", len(r.Sections))
+}
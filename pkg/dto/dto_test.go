@@ -0,0 +1,30 @@
+package dto_test
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"c7n-helper/pkg/dto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportReadSingle(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "resources.json")
+	single := dto.PolicyReport{Type: "eks", Policy: "expired", Accounts: []dto.Account{{Name: "dev"}}}
+	assert.NoError(t, single.WriteToFile(file))
+	var report dto.Report
+	assert.NoError(t, report.ReadFromFile(file))
+	assert.Len(t, report.Sections, 1)
+	assert.Equal(t, "eks", report.Sections[0].Type)
+
+	report.Sections = append(report.Sections, dto.PolicyReport{Type: "gke", Policy: "expired-gke"})
+	assert.NoError(t, report.WriteToFile(file))
+	var multi dto.Report
+	assert.NoError(t, multi.ReadFromFile(file))
+	expected, err := json.Marshal(report)
+	assert.NoError(t, err)
+	actual, err := json.Marshal(multi)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(expected), string(actual))
+}
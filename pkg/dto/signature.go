@@ -0,0 +1,103 @@
+package dto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// SigningKeyEnv is environment variable with the report signing key, used if the key file is not set
+const SigningKeyEnv = "C7N_HELPER_SIGNING_KEY"
+
+// Verification is resource file checks before destructive actions, disabled if empty
+type Verification struct {
+	Key    []byte
+	MaxAge time.Duration
+}
+
+// SigningKey reads the key from the file or SigningKeyEnv environment variable
+func SigningKey(keyFile string) ([]byte, error) {
+	key := os.Getenv(SigningKeyEnv)
+	if keyFile != "" {
+		content, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		key = string(content)
+	}
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return nil, fmt.Errorf("signing key is empty, set %s or key file", SigningKeyEnv)
+	}
+	return []byte(key), nil
+}
+
+// Sign sets the report generation time and HMAC-SHA256 signature of the report content
+func (r *PolicyReport) Sign(key []byte) error {
+	generated := time.Now().UTC()
+	r.Generated = &generated
+	r.Signature = ""
+	content, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	r.Signature, err = Signature(key, content)
+	return err
+}
+
+// Verify checks the report signature if the key is set and the report age if the max age is set.
+// The signature of a read report is checked against the read content, so fields unknown
+// to this version are covered and fields added later don't change the signed content.
+func (r *PolicyReport) Verify(v Verification) error {
+	if len(v.Key) > 0 {
+		if r.Signature == "" {
+			return errors.New("resource file is not signed")
+		}
+		content := r.raw
+		if content == nil {
+			var err error
+			if content, err = json.Marshal(r); err != nil {
+				return err
+			}
+		}
+		expected, err := Signature(v.Key, content)
+		if err != nil {
+			return err
+		}
+		if !hmac.Equal([]byte(expected), []byte(r.Signature)) {
+			return errors.New("resource file signature is invalid")
+		}
+	}
+	if v.MaxAge > 0 {
+		if r.Generated == nil || r.Generated.IsZero() {
+			return errors.New("resource file generation time is missing")
+		}
+		if age := time.Since(*r.Generated); age > v.MaxAge {
+			return fmt.Errorf("resource file is too old: generated %s ago, max age %s", age.Round(time.Second), v.MaxAge)
+		}
+	}
+	return nil
+}
+
+// Signature is HMAC-SHA256 of the canonical form of JSON object content:
+// the object without the top-level signature field, keys sorted and whitespace removed
+func Signature(key, content []byte) (string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(content, &fields); err != nil {
+		return "", err
+	}
+	delete(fields, "signature")
+	canonical, err := json.Marshal(fields)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(canonical)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
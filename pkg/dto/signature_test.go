@@ -0,0 +1,55 @@
+package dto_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"c7n-helper/pkg/dto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	key := []byte("secret")
+	report := dto.PolicyReport{
+		Type:   "eks",
+		Policy: "expired",
+		Accounts: []dto.Account{{Name: "dev", Resources: []dto.Resource{
+			{Name: "cluster-1", Location: "us-east-1", Created: time.Now(), Expiry: time.Now()},
+		}}},
+	}
+	assert.NoError(t, report.Sign(key))
+	file := filepath.Join(t.TempDir(), "resources.json")
+	assert.NoError(t, report.WriteToFile(file))
+
+	var read dto.PolicyReport
+	assert.NoError(t, read.ReadFromFile(file))
+	assert.NoError(t, read.Verify(dto.Verification{Key: key, MaxAge: time.Hour}))
+	assert.Error(t, read.Verify(dto.Verification{Key: []byte("another")}))
+	assert.Error(t, read.Verify(dto.Verification{MaxAge: time.Nanosecond}))
+
+	content, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	assertVerify := func(content []byte) error {
+		changed := filepath.Join(t.TempDir(), "resources.json")
+		assert.NoError(t, os.WriteFile(changed, content, 0644))
+		var read dto.PolicyReport
+		assert.NoError(t, read.ReadFromFile(changed))
+		return read.Verify(dto.Verification{Key: key})
+	}
+	var compact bytes.Buffer
+	assert.NoError(t, json.Compact(&compact, content))
+	assert.NoError(t, assertVerify(compact.Bytes()))
+	assert.Error(t, assertVerify(bytes.Replace(content, []byte("cluster-1"), []byte("cluster-2"), 1)))
+	assert.Error(t, assertVerify(bytes.Replace(content, []byte("{"), []byte(`{"unknown": true,`), 1)))
+
+	unsigned := dto.PolicyReport{Type: "eks"}
+	assert.Error(t, unsigned.Verify(dto.Verification{Key: key}))
+	assert.NoError(t, unsigned.Verify(dto.Verification{}))
+	unsignedContent, err := json.Marshal(&unsigned)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(unsignedContent), "generated")
+}
@@ -0,0 +1,133 @@
+package expiry
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"c7n-helper/pkg/dto"
+)
+
+// Expiry statuses saved to the report, empty status means the expiry is read from the tag
+const (
+	// Missing expiry tag, the default expiry is used
+	Missing = "missing"
+	// Never expires, the expiry is zero time
+	Never = "never"
+	// Invalid expiry tag value, the default expiry is used
+	Invalid = "invalid"
+	// Missing expiry tag, the expiry is created time plus the default TTL
+	Defaulted = "defaulted"
+)
+
+const ttlPrefix = "ttl="
+
+// SkipNever returns accounts without resources that never expire, destructive commands must not touch them.
+// Skipped resources are reported to the skip function.
+func SkipNever(accounts []dto.Account, skip func(account string, resource dto.Resource)) []dto.Account {
+	result := make([]dto.Account, 0, len(accounts))
+	for _, account := range accounts {
+		resources := make([]dto.Resource, 0, len(account.Resources))
+		for _, resource := range account.Resources {
+			if resource.ExpiryStatus == Never {
+				skip(account.Name, resource)
+				continue
+			}
+			resources = append(resources, resource)
+		}
+		if len(resources) > 0 {
+			result = append(result, dto.Account{Name: account.Name, Resources: resources})
+		}
+	}
+	return result
+}
+
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02",
+	"2006-01-02 15:04:05",
+}
+
+// C7N mark-for-op date layouts: `<message>: <op>@2025/01/01` or with hours `<op>@2025/01/01 1500 UTC`
+var markForOpLayouts = []string{
+	"2006/01/02",
+	"2006/01/02 1504 MST",
+}
+
+// Parse returns expiry time of the tag value, created time is the base of `ttl=<duration>` values.
+// Duration is Go duration or number of days (`7d`) or weeks (`2w`).
+func Parse(value string, created time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	switch strings.ToLower(value) {
+	case "never", "permanent":
+		return time.Time{}, nil
+	}
+	if ttl, ok := strings.CutPrefix(strings.ToLower(value), ttlPrefix); ok {
+		if created.IsZero() {
+			return time.Time{}, fmt.Errorf("ttl %s without creation time", ttl)
+		}
+		duration, err := ParseDuration(ttl)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return created.Add(duration), nil
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	if i := strings.LastIndex(value, "@"); i >= 0 {
+		for _, layout := range markForOpLayouts {
+			if t, err := time.Parse(layout, value[i+1:]); err == nil {
+				return t, nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("unsupported expiry %q", value)
+}
+
+// Resolve returns expiry time and status of the tag value, the default expiry is used if the value is missing or invalid
+func Resolve(value string, created, defaultExpiry time.Time) (time.Time, string) {
+	if strings.TrimSpace(value) == "" {
+		return defaultExpiry, Missing
+	}
+	t, err := Parse(value, created)
+	if err != nil {
+		return defaultExpiry, Invalid
+	}
+	if t.IsZero() {
+		return t, Never
+	}
+	return t, ""
+}
+
+// Format returns the expiry date or status if the expiry is not read from the tag
+func Format(t time.Time, status string) string {
+	switch status {
+	case Never, Invalid:
+		return status
+	case Defaulted:
+		return t.Format("2006-01-02") + " (default)"
+	}
+	return t.Format("2006-01-02")
+}
+
+// ParseDuration parses Go duration or number of days (`7d`) or weeks (`2w`)
+func ParseDuration(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if number, ok := strings.CutSuffix(s, suffix); ok {
+			n, err := strconv.Atoi(number)
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("invalid ttl %s", s)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+	duration, err := time.ParseDuration(s)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("invalid ttl %s", s)
+	}
+	return duration, nil
+}
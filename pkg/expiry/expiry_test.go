@@ -0,0 +1,72 @@
+package expiry_test
+
+import (
+	"testing"
+	"time"
+
+	"c7n-helper/pkg/dto"
+	"c7n-helper/pkg/expiry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	tests := map[string]time.Time{
+		"2024-06-01":           time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+		"2024-06-01 12:30:00":  time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC),
+		"2024-06-01T12:30:00Z": time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC),
+		"ttl=7d":               time.Date(2024, 5, 8, 10, 0, 0, 0, time.UTC),
+		"TTL=2w":               time.Date(2024, 5, 15, 10, 0, 0, 0, time.UTC),
+		"ttl=36h":              time.Date(2024, 5, 2, 22, 0, 0, 0, time.UTC),
+		"never":                {},
+		"Permanent":            {},
+		"Resource does not meet policy: delete@2025/01/01":        time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		"Resource does not meet policy: stop@2025/01/01 1500 UTC": time.Date(2025, 1, 1, 15, 0, 0, 0, time.UTC),
+	}
+	for value, expected := range tests {
+		actual, err := expiry.Parse(value, created)
+		assert.NoError(t, err, value)
+		assert.Equal(t, expected, actual, value)
+	}
+	for _, value := range []string{"tomorrow", "ttl=7x", "ttl=-1d", "delete@soon", "01/06/2024"} {
+		_, err := expiry.Parse(value, created)
+		assert.Error(t, err, value)
+	}
+	_, err := expiry.Parse("ttl=7d", time.Time{})
+	assert.Error(t, err)
+}
+
+func TestResolve(t *testing.T) {
+	created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	defaultExpiry := created.Add(time.Hour)
+
+	actual, status := expiry.Resolve("", created, defaultExpiry)
+	assert.Equal(t, defaultExpiry, actual)
+	assert.Equal(t, expiry.Missing, status)
+
+	actual, status = expiry.Resolve("next week", created, defaultExpiry)
+	assert.Equal(t, defaultExpiry, actual)
+	assert.Equal(t, expiry.Invalid, status)
+	assert.Equal(t, "invalid", expiry.Format(actual, status))
+
+	actual, status = expiry.Resolve("never", created, defaultExpiry)
+	assert.True(t, actual.IsZero())
+	assert.Equal(t, expiry.Never, status)
+
+	actual, status = expiry.Resolve("2024-06-01", created, defaultExpiry)
+	assert.Equal(t, "", status)
+	assert.Equal(t, "2024-06-01", expiry.Format(actual, status))
+}
+
+func TestSkipNever(t *testing.T) {
+	accounts := []dto.Account{
+		{Name: "dev", Resources: []dto.Resource{{Name: "cluster-1"}, {Name: "cluster-2", ExpiryStatus: expiry.Never}}},
+		{Name: "prod", Resources: []dto.Resource{{Name: "cluster-3", ExpiryStatus: expiry.Never}}},
+	}
+	var skipped []string
+	result := expiry.SkipNever(accounts, func(account string, resource dto.Resource) {
+		skipped = append(skipped, account+"/"+resource.Name)
+	})
+	assert.Equal(t, []dto.Account{{Name: "dev", Resources: []dto.Resource{{Name: "cluster-1"}}}}, result)
+	assert.Equal(t, []string{"dev/cluster-2", "prod/cluster-3"}, skipped)
+}
@@ -1,65 +1,70 @@
 package gcp
 
 import (
-	"encoding/json"
+	"io"
 	"strings"
 	"time"
 
 	"c7n-helper/pkg/date"
 	"c7n-helper/pkg/dto"
+	"c7n-helper/pkg/expiry"
+	"c7n-helper/pkg/jsonstream"
+	"c7n-helper/pkg/tagschema"
 )
 
-const ttl = time.Hour * 24 * 10 // 10 days
+type gkeCluster struct {
+	Name      string            `json:"name"`
+	Location  string            `json:"location"`
+	CreatedAt time.Time         `json:"createTime"`
+	Labels    map[string]string `json:"labels"`
+}
 
-type labels struct {
-	Owner  string `json:"owner"`
-	Expiry string `json:"expiry"`
+type gceInstance struct {
+	Name       string            `json:"name"`
+	Zone       string            `json:"zone"`
+	LaunchTime time.Time         `json:"creationTimestamp"`
+	Labels     map[string]string `json:"labels"`
 }
 
-func GKE(_ string, content []byte) ([]dto.Resource, error) {
-	var clusters []struct {
-		Name      string    `json:"name"`
-		Location  string    `json:"location"`
-		CreatedAt time.Time `json:"createTime"`
-		Labels    labels    `json:"labels"`
-	}
-	if err := json.Unmarshal(content, &clusters); err != nil {
-		return nil, err
-	}
-	result := make([]dto.Resource, 0, len(clusters))
-	for _, cluster := range clusters {
+func GKE(_ string, r io.Reader, schema tagschema.Config) ([]dto.Resource, error) {
+	result := make([]dto.Resource, 0)
+	err := jsonstream.DecodeArray(r, func(cluster gkeCluster) error {
+		values := schema.GCP.Resolve(cluster.Labels)
+		expiresAt, expiryStatus := expiry.Resolve(values.Expiry, cluster.CreatedAt, time.Now())
 		result = append(result, dto.Resource{
-			Name:     cluster.Name,
-			Location: cluster.Location,
-			Owner:    cluster.Labels.Owner,
-			Created:  cluster.CreatedAt,
-			Expiry:   date.ParseOrDefault(cluster.Labels.Expiry, cluster.CreatedAt.Add(ttl)),
+			Name:         cluster.Name,
+			Location:     cluster.Location,
+			Owner:        values.Owner,
+			Team:         values.Team,
+			Created:      cluster.CreatedAt,
+			Expiry:       expiresAt,
+			ExpiryStatus: expiryStatus,
+			DeleteAfter:  date.ParseOrDefault(cluster.Labels[deleteAfterLabel], time.Time{}),
+			Sources:      values.Sources,
 		})
-	}
-	return result, nil
+		return nil
+	})
+	return result, err
 }
 
-func GCE(_ string, content []byte) ([]dto.Resource, error) {
-	var vms []struct {
-		Name       string    `json:"name"`
-		Zone       string    `json:"zone"`
-		LaunchTime time.Time `json:"creationTimestamp"`
-		Labels     labels    `json:"labels"`
-	}
-	if err := json.Unmarshal(content, &vms); err != nil {
-		return nil, err
-	}
-	result := make([]dto.Resource, 0, len(vms))
-	for _, vm := range vms {
+func GCE(_ string, r io.Reader, schema tagschema.Config) ([]dto.Resource, error) {
+	result := make([]dto.Resource, 0)
+	err := jsonstream.DecodeArray(r, func(vm gceInstance) error {
+		values := schema.GCP.Resolve(vm.Labels)
+		expiresAt, expiryStatus := expiry.Resolve(values.Expiry, vm.LaunchTime, time.Now())
 		result = append(result, dto.Resource{
-			Name:     vm.Name,
-			Location: normalizeZone(vm.Zone),
-			Owner:    vm.Labels.Owner,
-			Created:  vm.LaunchTime,
-			Expiry:   date.ParseOrDefault(vm.Labels.Expiry, vm.LaunchTime.Add(ttl)),
+			Name:         vm.Name,
+			Location:     normalizeZone(vm.Zone),
+			Owner:        values.Owner,
+			Team:         values.Team,
+			Created:      vm.LaunchTime,
+			Expiry:       expiresAt,
+			ExpiryStatus: expiryStatus,
+			Sources:      values.Sources,
 		})
-	}
-	return result, nil
+		return nil
+	})
+	return result, err
 }
 
 // Zone value: `https://www.googleapis.com/compute/v1/projects/<project-name>/zones/us-central1-a`
@@ -0,0 +1,54 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+
+	"c7n-helper/pkg/dto"
+	"c7n-helper/pkg/log"
+	"go.uber.org/multierr"
+	"google.golang.org/api/container/v1"
+)
+
+// Scheduled deletion date label, GCP label keys can't contain slashes
+const deleteAfterLabel = "c7n-helper-delete-after"
+
+// MarkGKE labels GKE clusters with the scheduled deletion date, already marked clusters are not changed.
+// Application default credentials are used, account name is GCP project ID.
+func MarkGKE(ctx context.Context, accounts []dto.Account, deleteAfter string) (errs error) {
+	service, err := container.NewService(ctx)
+	if err != nil {
+		return err
+	}
+	for _, account := range accounts {
+		for _, resource := range account.Resources {
+			ctx, _ := log.UpdateContext(ctx, "project", account.Name, "gke", resource.Name)
+			name := fmt.Sprintf("projects/%s/locations/%s/clusters/%s", account.Name, resource.Location, resource.Name)
+			errs = multierr.Append(errs, markCluster(ctx, service, name, deleteAfter))
+		}
+	}
+	return
+}
+
+func markCluster(ctx context.Context, service *container.Service, name, deleteAfter string) error {
+	logger := log.FromContext(ctx)
+	cluster, err := service.Projects.Locations.Clusters.Get(name).Context(ctx).Do()
+	if err != nil {
+		return err
+	}
+	if value, ok := cluster.ResourceLabels[deleteAfterLabel]; ok {
+		logger.Infof("already marked for deletion after %s", value)
+		return nil
+	}
+	logger.Infof("marking for deletion after %s", deleteAfter)
+	labels := make(map[string]string, len(cluster.ResourceLabels)+1)
+	for k, v := range cluster.ResourceLabels {
+		labels[k] = v
+	}
+	labels[deleteAfterLabel] = deleteAfter
+	_, err = service.Projects.Locations.Clusters.SetResourceLabels(name, &container.SetLabelsRequest{
+		ResourceLabels:   labels,
+		LabelFingerprint: cluster.LabelFingerprint,
+	}).Context(ctx).Do()
+	return err
+}
@@ -0,0 +1,27 @@
+package jsonstream_test
+
+import (
+	"strings"
+	"testing"
+
+	"c7n-helper/pkg/jsonstream"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeArray(t *testing.T) {
+	type item struct {
+		Name string `json:"name"`
+	}
+	names := make([]string, 0)
+	collect := func(i item) error {
+		names = append(names, i.Name)
+		return nil
+	}
+	assert.NoError(t, jsonstream.DecodeArray(strings.NewReader(`[{"name": "a", "other": {"x": [1]}}, {"name": "b"}]`), collect))
+	assert.Equal(t, []string{"a", "b"}, names)
+
+	assert.NoError(t, jsonstream.DecodeArray(strings.NewReader(`null`), collect))
+	assert.Error(t, jsonstream.DecodeArray(strings.NewReader(`{"name": "a"}`), collect))
+	assert.Error(t, jsonstream.DecodeArray(strings.NewReader(`[{"name": "a"}`), collect))
+	assert.Error(t, jsonstream.DecodeArray(strings.NewReader(``), collect))
+}
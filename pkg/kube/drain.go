@@ -0,0 +1,289 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"c7n-helper/pkg/log"
+	"go.uber.org/multierr"
+)
+
+const (
+	servicesPath          = "/api/v1/services"
+	ingressesPath         = "/apis/networking.k8s.io/v1/ingresses"
+	claimsPath            = "/api/v1/persistentvolumeclaims"
+	podsPath              = "/api/v1/pods"
+	persistentVolumesPath = "/api/v1/persistentvolumes"
+	maxPollInterval       = time.Second * 5
+)
+
+/*
+Drain deletes objects that make in-cluster controllers create cloud resources: Services of LoadBalancer type (ELBs),
+Ingresses (ALBs) and PersistentVolumeClaims (EBS volumes). Workloads of pods that use the claims are scaled to zero,
+so controllers don't recreate the pods and StatefulSets don't recreate their volume claim templates.
+Then it waits until the objects and bound PersistentVolumes are gone, so the controllers have released the cloud resources.
+Objects are tracked by UID, an object recreated with the same name is reported instead of being waited for.
+*/
+func (c *Client) Drain(ctx context.Context, timeout time.Duration) error {
+	logger := log.FromContext(ctx)
+
+	services, err := c.listOrEmpty(ctx, servicesPath)
+	if err != nil {
+		return err
+	}
+	loadBalancers := make([]object, 0)
+	for _, service := range services {
+		if service.Spec.Type == "LoadBalancer" {
+			loadBalancers = append(loadBalancers, service)
+		}
+	}
+	logger.Infof("deleting kubernetes load balancer services: %d", len(loadBalancers))
+	if err := c.deleteAll(ctx, "services", loadBalancers); err != nil {
+		return err
+	}
+
+	ingresses, err := c.listOrEmpty(ctx, ingressesPath)
+	if err != nil {
+		return err
+	}
+	logger.Infof("deleting kubernetes ingresses: %d", len(ingresses))
+	if err := c.deleteAll(ctx, "ingresses", ingresses); err != nil {
+		return err
+	}
+
+	claims, err := c.listOrEmpty(ctx, claimsPath)
+	if err != nil {
+		return err
+	}
+	volumes, err := c.listOrEmpty(ctx, persistentVolumesPath)
+	if err != nil {
+		return err
+	}
+	claimKeys := make(map[string]struct{}, len(claims))
+	for _, claim := range claims {
+		claimKeys[key(claim.Metadata.Namespace, claim.Metadata.Name)] = struct{}{}
+	}
+	boundVolumes := make([]object, 0)
+	for _, volume := range volumes {
+		if volume.Spec.ClaimRef == nil {
+			continue
+		}
+		if _, ok := claimKeys[key(volume.Spec.ClaimRef.Namespace, volume.Spec.ClaimRef.Name)]; ok {
+			boundVolumes = append(boundVolumes, volume)
+		}
+	}
+	// Claims are protected by `kubernetes.io/pvc-protection` finalizer while pods use them
+	pods, err := c.listOrEmpty(ctx, podsPath)
+	if err != nil {
+		return err
+	}
+	claimPods := make([]object, 0)
+	for _, pod := range pods {
+		for _, volume := range pod.Spec.Volumes {
+			if volume.PersistentVolumeClaim == nil {
+				continue
+			}
+			if _, ok := claimKeys[key(pod.Metadata.Namespace, volume.PersistentVolumeClaim.ClaimName)]; ok {
+				claimPods = append(claimPods, pod)
+				break
+			}
+		}
+	}
+	workloads, ownerlessPods, err := c.podWorkloads(ctx, claimPods)
+	if err != nil {
+		return err
+	}
+	logger.Infof("scaling kubernetes workloads using persistent volume claims to zero: %d", len(workloads))
+	if err := c.scaleToZero(ctx, workloads); err != nil {
+		return err
+	}
+	logger.Infof("deleting kubernetes pods using persistent volume claims without scalable workload: %d", len(ownerlessPods))
+	if err := c.deleteAll(ctx, "pods", ownerlessPods); err != nil {
+		return err
+	}
+
+	logger.Infof("deleting kubernetes persistent volume claims: %d", len(claims))
+	if err := c.deleteAll(ctx, "persistentvolumeclaims", claims); err != nil {
+		return err
+	}
+
+	logger.Infof("waiting for kubernetes objects deletion (timeout: %s)", timeout)
+	return c.waitDeleted(ctx, timeout, []deletedObjects{
+		{path: servicesPath, objects: loadBalancers},
+		{path: ingressesPath, objects: ingresses},
+		{path: podsPath, objects: claimPods},
+		{path: claimsPath, objects: claims},
+		{path: persistentVolumesPath, objects: boundVolumes},
+	})
+}
+
+// Scalable workload: apps/v1 resource, namespace and name
+type workload struct {
+	resource, namespace, name string
+}
+
+// Returns workloads that own the pods (ReplicaSets are resolved to their Deployments) and pods without a scalable
+// controller: bare pods and pods of DaemonSets, Jobs and other controllers
+func (c *Client) podWorkloads(ctx context.Context, pods []object) ([]workload, []object, error) {
+	seen := make(map[workload]struct{})
+	workloads := make([]workload, 0)
+	ownerless := make([]object, 0)
+	for _, pod := range pods {
+		w, ok, err := c.podWorkload(ctx, pod)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			ownerless = append(ownerless, pod)
+			continue
+		}
+		if _, ok := seen[w]; !ok {
+			seen[w] = struct{}{}
+			workloads = append(workloads, w)
+		}
+	}
+	return workloads, ownerless, nil
+}
+
+func (c *Client) podWorkload(ctx context.Context, pod object) (workload, bool, error) {
+	namespace := pod.Metadata.Namespace
+	owner, ok := controllerOf(pod)
+	if !ok {
+		return workload{}, false, nil
+	}
+	switch owner.Kind {
+	case "StatefulSet":
+		return workload{resource: "statefulsets", namespace: namespace, name: owner.Name}, true, nil
+	case "ReplicaSet":
+		replicaSet, err := c.get(ctx, fmt.Sprintf("/apis/apps/v1/namespaces/%s/replicasets/%s", namespace, owner.Name))
+		if errors.Is(err, errNotFound) {
+			return workload{}, false, nil
+		}
+		if err != nil {
+			return workload{}, false, err
+		}
+		if deployment, ok := controllerOf(replicaSet); ok && deployment.Kind == "Deployment" {
+			return workload{resource: "deployments", namespace: namespace, name: deployment.Name}, true, nil
+		}
+		return workload{resource: "replicasets", namespace: namespace, name: owner.Name}, true, nil
+	}
+	return workload{}, false, nil
+}
+
+func controllerOf(obj object) (ownerReference, bool) {
+	for _, owner := range obj.Metadata.OwnerReferences {
+		if owner.Controller {
+			return owner, true
+		}
+	}
+	return ownerReference{}, false
+}
+
+func (c *Client) scaleToZero(ctx context.Context, workloads []workload) (errs error) {
+	for _, w := range workloads {
+		path := fmt.Sprintf("/apis/apps/v1/namespaces/%s/%s/%s/scale", w.namespace, w.resource, w.name)
+		err := c.patch(ctx, path, []byte(`{"spec":{"replicas":0}}`))
+		if errors.Is(err, errNotFound) {
+			continue
+		}
+		errs = multierr.Append(errs, err)
+	}
+	return
+}
+
+type deletedObjects struct {
+	path    string
+	objects []object
+}
+
+func (c *Client) waitDeleted(ctx context.Context, timeout time.Duration, deleted []deletedObjects) error {
+	interval := min(timeout/20, maxPollInterval)
+	deadline := time.Now().Add(timeout)
+	for {
+		remaining := make([]string, 0)
+		recreated := make([]string, 0)
+		for _, d := range deleted {
+			if len(d.objects) == 0 {
+				continue
+			}
+			current, err := c.listOrEmpty(ctx, d.path)
+			if err != nil {
+				return err
+			}
+			left, created := intersect(d.objects, current)
+			if len(left) > 0 {
+				remaining = append(remaining, fmt.Sprintf("%s: %s", d.path, strings.Join(left, ",")))
+			}
+			if len(created) > 0 {
+				recreated = append(recreated, fmt.Sprintf("%s: %s", d.path, strings.Join(created, ",")))
+			}
+		}
+		if len(remaining) == 0 {
+			if len(recreated) > 0 {
+				return fmt.Errorf("kubernetes objects are recreated by controllers: %s", strings.Join(recreated, "; "))
+			}
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("kubernetes objects are not deleted in %s: %s", timeout, strings.Join(remaining, "; "))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (c *Client) listOrEmpty(ctx context.Context, path string) ([]object, error) {
+	objects, err := c.list(ctx, path)
+	if errors.Is(err, errNotFound) {
+		// API group is not served by the cluster
+		return nil, nil
+	}
+	return objects, err
+}
+
+func (c *Client) deleteAll(ctx context.Context, resource string, objects []object) (errs error) {
+	for _, obj := range objects {
+		path := fmt.Sprintf("/api/v1/namespaces/%s/%s/%s", obj.Metadata.Namespace, resource, obj.Metadata.Name)
+		if resource == "ingresses" {
+			path = fmt.Sprintf("/apis/networking.k8s.io/v1/namespaces/%s/%s/%s", obj.Metadata.Namespace, resource, obj.Metadata.Name)
+		}
+		errs = multierr.Append(errs, c.delete(ctx, path))
+	}
+	return
+}
+
+// Returns keys of the deleted objects that still exist (same UID) and of the objects recreated with the same key
+// and a new UID, objects without UID are matched by key
+func intersect(deleted, current []object) ([]string, []string) {
+	currentUIDs := make(map[string]string, len(current))
+	for _, obj := range current {
+		currentUIDs[key(obj.Metadata.Namespace, obj.Metadata.Name)] = obj.Metadata.UID
+	}
+	left := make([]string, 0)
+	recreated := make([]string, 0)
+	for _, obj := range deleted {
+		k := key(obj.Metadata.Namespace, obj.Metadata.Name)
+		uid, ok := currentUIDs[k]
+		switch {
+		case !ok:
+		case uid == obj.Metadata.UID:
+			left = append(left, k)
+		default:
+			recreated = append(recreated, k)
+		}
+	}
+	return left, recreated
+}
+
+func key(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
+}
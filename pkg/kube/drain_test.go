@@ -0,0 +1,237 @@
+package kube_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"c7n-helper/pkg/kube"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeAPIServer keeps objects by list path, claims used by pods stay terminating until the pods are deleted (pvc-protection).
+// Scaling a workload to zero removes pods with the workload in `workload` metadata field.
+type fakeAPIServer struct {
+	mu          sync.Mutex
+	objects     map[string][]map[string]interface{}
+	replicaSets map[string]map[string]interface{}
+	terminating map[string]struct{}
+	deleted     []string
+	scaled      []string
+}
+
+func newFakeAPIServer() *fakeAPIServer {
+	return &fakeAPIServer{
+		terminating: make(map[string]struct{}),
+		objects: map[string][]map[string]interface{}{
+			"/api/v1/services": {
+				obj("default", "web", map[string]interface{}{"type": "LoadBalancer"}),
+				obj("default", "internal", map[string]interface{}{"type": "ClusterIP"}),
+			},
+			"/apis/networking.k8s.io/v1/ingresses": {
+				obj("default", "alb", nil),
+			},
+			"/api/v1/persistentvolumeclaims": {
+				obj("db", "data", nil),
+				obj("app", "cache", nil),
+				obj("tools", "scratch", nil),
+			},
+			"/api/v1/pods": {
+				owned(claimPod("db", "postgres-0", "data"), "StatefulSet", "postgres", "statefulsets/db/postgres"),
+				owned(claimPod("app", "api-5d4-x1", "cache"), "ReplicaSet", "api-5d4", "deployments/app/api"),
+				claimPod("tools", "debug", "scratch"),
+				obj("default", "web-1", nil),
+			},
+			"/api/v1/persistentvolumes": {
+				obj("", "pv-1", map[string]interface{}{"claimRef": map[string]interface{}{"namespace": "db", "name": "data"}}),
+			},
+		},
+		replicaSets: map[string]map[string]interface{}{
+			"app/api-5d4": owned(obj("app", "api-5d4", nil), "Deployment", "api", ""),
+		},
+	}
+}
+
+func obj(namespace, name string, spec map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"metadata": map[string]interface{}{"namespace": namespace, "name": name, "uid": namespace + "/" + name + "/1"},
+		"spec":     spec,
+	}
+}
+
+func claimPod(namespace, name, claim string) map[string]interface{} {
+	return obj(namespace, name, map[string]interface{}{"volumes": []interface{}{
+		map[string]interface{}{"persistentVolumeClaim": map[string]interface{}{"claimName": claim}},
+	}})
+}
+
+func owned(o map[string]interface{}, kind, name, workload string) map[string]interface{} {
+	metadata := o["metadata"].(map[string]interface{})
+	metadata["ownerReferences"] = []interface{}{map[string]interface{}{"kind": kind, "name": name, "controller": true}}
+	metadata["workload"] = workload
+	return o
+}
+
+func (f *fakeAPIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodGet:
+		if rest, ok := strings.CutPrefix(r.URL.Path, "/apis/apps/v1/namespaces/"); ok {
+			namespace, name, _ := strings.Cut(rest, "/replicasets/")
+			replicaSet, ok := f.replicaSets[namespace+"/"+name]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(replicaSet)
+			return
+		}
+		items, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"items": items})
+	case http.MethodPatch:
+		// /apis/apps/v1/namespaces/<ns>/<resource>/<name>/scale
+		parts := strings.Split(r.URL.Path, "/")
+		l := len(parts)
+		if r.Header.Get("Content-Type") != "application/merge-patch+json" || parts[l-1] != "scale" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		workload := parts[l-3] + "/" + parts[l-4] + "/" + parts[l-2]
+		f.scaled = append(f.scaled, workload)
+		pods := make([]map[string]interface{}, 0)
+		for _, pod := range f.objects["/api/v1/pods"] {
+			if pod["metadata"].(map[string]interface{})["workload"] != workload {
+				pods = append(pods, pod)
+			}
+		}
+		f.objects["/api/v1/pods"] = pods
+		f.releaseClaims()
+		_, _ = w.Write([]byte("{}"))
+	case http.MethodDelete:
+		// /api/v1/namespaces/<ns>/<resource>/<name> or /apis/<group>/<version>/namespaces/<ns>/<resource>/<name>
+		parts := strings.Split(r.URL.Path, "/")
+		l := len(parts)
+		namespace, resource, name := parts[l-3], parts[l-2], parts[l-1]
+		f.deleted = append(f.deleted, resource+"/"+namespace+"/"+name)
+		switch resource {
+		case "persistentvolumeclaims":
+			f.terminating[namespace+"/"+name] = struct{}{}
+		default:
+			f.remove(strings.Join(parts[:l-4], "/")+"/"+resource, namespace, name)
+		}
+		f.releaseClaims()
+		_, _ = w.Write([]byte("{}"))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeAPIServer) remove(path, namespace, name string) {
+	items := make([]map[string]interface{}, 0)
+	for _, item := range f.objects[path] {
+		metadata := item["metadata"].(map[string]interface{})
+		if metadata["namespace"] != namespace || metadata["name"] != name {
+			items = append(items, item)
+		}
+	}
+	f.objects[path] = items
+}
+
+// Removes terminating claims when no pods are left in the claim namespace, the bound volume is removed together with the claim
+func (f *fakeAPIServer) releaseClaims() {
+	for claim := range f.terminating {
+		namespace, name, _ := strings.Cut(claim, "/")
+		inUse := false
+		for _, pod := range f.objects["/api/v1/pods"] {
+			metadata := pod["metadata"].(map[string]interface{})
+			if metadata["namespace"] == namespace {
+				inUse = true
+			}
+		}
+		if inUse {
+			continue
+		}
+		delete(f.terminating, claim)
+		f.remove("/api/v1/persistentvolumeclaims", namespace, name)
+		f.remove("/api/v1/persistentvolumes", "", "pv-1")
+	}
+}
+
+func TestDrain(t *testing.T) {
+	fake := newFakeAPIServer()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client, err := kube.NewClient(kube.Config{Server: server.URL, Token: "token"})
+	assert.NoError(t, err)
+	assert.NoError(t, client.Drain(context.Background(), time.Second))
+
+	assert.ElementsMatch(t, []string{
+		"services/default/web",
+		"ingresses/default/alb",
+		"persistentvolumeclaims/db/data",
+		"persistentvolumeclaims/app/cache",
+		"persistentvolumeclaims/tools/scratch",
+		"pods/tools/debug",
+	}, fake.deleted)
+	assert.ElementsMatch(t, []string{"statefulsets/db/postgres", "deployments/app/api"}, fake.scaled)
+	assert.Len(t, fake.objects["/api/v1/services"], 1)
+	assert.Len(t, fake.objects["/api/v1/pods"], 1)
+	assert.Empty(t, fake.objects["/apis/networking.k8s.io/v1/ingresses"])
+	assert.Empty(t, fake.objects["/api/v1/persistentvolumeclaims"])
+	assert.Empty(t, fake.objects["/api/v1/persistentvolumes"])
+}
+
+func TestDrainTimeout(t *testing.T) {
+	fake := newFakeAPIServer()
+	// finalizer never completes: deletes are accepted but objects stay
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		fake.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	client, err := kube.NewClient(kube.Config{Server: server.URL, Token: "token"})
+	assert.NoError(t, err)
+	err = client.Drain(context.Background(), time.Millisecond*200)
+	assert.ErrorContains(t, err, "default/web")
+}
+
+func TestDrainRecreated(t *testing.T) {
+	fake := newFakeAPIServer()
+	// a controller recreates the claim with the same name right after the deletion
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fake.ServeHTTP(w, r)
+		if r.Method == http.MethodDelete && strings.HasSuffix(r.URL.Path, "/persistentvolumeclaims/scratch") {
+			fake.mu.Lock()
+			defer fake.mu.Unlock()
+			recreated := obj("tools", "scratch", nil)
+			recreated["metadata"].(map[string]interface{})["uid"] = "tools/scratch/2"
+			fake.objects["/api/v1/persistentvolumeclaims"] = append(fake.objects["/api/v1/persistentvolumeclaims"], recreated)
+		}
+	}))
+	defer server.Close()
+
+	client, err := kube.NewClient(kube.Config{Server: server.URL, Token: "token"})
+	assert.NoError(t, err)
+	err = client.Drain(context.Background(), time.Second*10)
+	assert.ErrorContains(t, err, "recreated")
+	assert.ErrorContains(t, err, "tools/scratch")
+}
@@ -0,0 +1,158 @@
+package kube
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const requestTimeout = time.Second * 30
+
+// Config is a minimal kubeconfig: API server endpoint, PEM encoded CA and bearer token
+type Config struct {
+	Server                   string
+	CertificateAuthorityData []byte
+	Token                    string
+}
+
+type Client struct {
+	server string
+	token  string
+	http   *http.Client
+}
+
+type object struct {
+	Metadata struct {
+		Name            string           `json:"name"`
+		Namespace       string           `json:"namespace"`
+		UID             string           `json:"uid"`
+		OwnerReferences []ownerReference `json:"ownerReferences"`
+	} `json:"metadata"`
+	Spec struct {
+		Type     string `json:"type"`
+		ClaimRef *ref   `json:"claimRef"`
+		Volumes  []struct {
+			PersistentVolumeClaim *struct {
+				ClaimName string `json:"claimName"`
+			} `json:"persistentVolumeClaim"`
+		} `json:"volumes"`
+	} `json:"spec"`
+}
+
+type ref struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+type ownerReference struct {
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Controller bool   `json:"controller"`
+}
+
+type objectList struct {
+	Items    []object `json:"items"`
+	Metadata struct {
+		Continue string `json:"continue"`
+	} `json:"metadata"`
+}
+
+func NewClient(config Config) (*Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if len(config.CertificateAuthorityData) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(config.CertificateAuthorityData) {
+			return nil, errors.New("invalid kubernetes certificate authority data")
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	return &Client{
+		server: strings.TrimSuffix(config.Server, "/"),
+		token:  config.Token,
+		http:   &http.Client{Transport: transport, Timeout: requestTimeout},
+	}, nil
+}
+
+func (c *Client) list(ctx context.Context, path string) ([]object, error) {
+	result := make([]object, 0)
+	cont := ""
+	for {
+		url := path
+		if cont != "" {
+			url += "?continue=" + cont
+		}
+		var list objectList
+		if err := c.do(ctx, http.MethodGet, url, "", nil, &list); err != nil {
+			return nil, err
+		}
+		result = append(result, list.Items...)
+		if list.Metadata.Continue == "" {
+			return result, nil
+		}
+		cont = list.Metadata.Continue
+	}
+}
+
+func (c *Client) get(ctx context.Context, path string) (object, error) {
+	var obj object
+	err := c.do(ctx, http.MethodGet, path, "", nil, &obj)
+	return obj, err
+}
+
+func (c *Client) delete(ctx context.Context, path string) error {
+	body := []byte(`{"kind":"DeleteOptions","apiVersion":"v1","propagationPolicy":"Background"}`)
+	err := c.do(ctx, http.MethodDelete, path, "application/json", body, nil)
+	if errors.Is(err, errNotFound) {
+		return nil
+	}
+	return err
+}
+
+// Applies JSON merge patch to the object
+func (c *Client) patch(ctx context.Context, path string, body []byte) error {
+	return c.do(ctx, http.MethodPatch, path, "application/merge-patch+json", body, nil)
+}
+
+var errNotFound = errors.New("not found")
+
+func (c *Client) do(ctx context.Context, method, path, contentType string, in []byte, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		body = bytes.NewReader(in)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.server+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode == http.StatusNotFound {
+		return errNotFound
+	}
+	if res.StatusCode >= http.StatusBadRequest {
+		content, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("kubernetes api %s %s: %s: %s", method, path, res.Status, strings.TrimSpace(string(content)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
@@ -0,0 +1,62 @@
+package marker
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"c7n-helper/pkg/aws"
+	"c7n-helper/pkg/azure"
+	"c7n-helper/pkg/dto"
+	"c7n-helper/pkg/expiry"
+	"c7n-helper/pkg/gcp"
+	"c7n-helper/pkg/log"
+	"go.uber.org/multierr"
+)
+
+// Mark writes deletion date (now + grace period) into resource tags or labels of all supported report sections
+func Mark(ctx context.Context, resourceFile string, grace time.Duration) error {
+	logger := log.FromContext(ctx)
+	logger.Info("reading resource file...")
+	var report dto.Report
+	if err := report.ReadFromFile(resourceFile); err != nil {
+		return err
+	}
+	deleteAfter := time.Now().UTC().Add(grace).Format("2006-01-02")
+	logger.Infof("marking resources for deletion after %s...", deleteAfter)
+	var (
+		errs   error
+		marked bool
+	)
+	for _, section := range report.Sections {
+		resourceType := strings.ToLower(section.Type)
+		section.Accounts = expiry.SkipNever(section.Accounts, func(account string, resource dto.Resource) {
+			logger.Warnf("skipping %s/%s/%s: resource never expires", account, resource.Location, resource.Name)
+		})
+		switch {
+		case aws.IsMarkable(resourceType):
+			logger.Info("preparing aws clients...")
+			if err := aws.InitClientsMap(ctx, section.Accounts); err != nil {
+				return err
+			}
+			errs = multierr.Append(errs, aws.MarkResources(ctx, resourceType, section.Accounts, deleteAfter))
+		case resourceType == "gke":
+			errs = multierr.Append(errs, gcp.MarkGKE(ctx, section.Accounts, deleteAfter))
+		case resourceType == "arg":
+			errs = multierr.Append(errs, azure.MarkRG(ctx, section.Accounts, deleteAfter))
+		default:
+			logger.Infof("skipping unsupported %s section of %s policy", resourceType, section.Policy)
+			continue
+		}
+		marked = true
+	}
+	if !marked {
+		return errors.New("unsupported resource type")
+	}
+	if errs != nil {
+		return errs
+	}
+	logger.Info("finished successful")
+	return nil
+}
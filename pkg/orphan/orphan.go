@@ -0,0 +1,38 @@
+package orphan
+
+import (
+	"context"
+
+	"c7n-helper/pkg/aws"
+	"c7n-helper/pkg/dto"
+	"c7n-helper/pkg/log"
+)
+
+const ReportType = "eks-orphan"
+
+func Find(ctx context.Context, profiles, regions []string, outFile string) error {
+	logger := log.FromContext(ctx)
+	if len(profiles) == 0 {
+		logger.Info("reading aws shared config profiles...")
+		var err error
+		if profiles, err = aws.SharedConfigProfiles(); err != nil {
+			return err
+		}
+		if len(profiles) == 0 {
+			profiles = []string{"default"}
+		}
+	}
+	logger.Infof("scanning %d accounts in %d regions...", len(profiles), len(regions))
+	accounts, findErr := aws.FindOrphans(ctx, profiles, regions)
+	report := dto.PolicyReport{
+		Type:     ReportType,
+		Policy:   "orphans",
+		Accounts: accounts,
+	}
+	logger.Infof("saving %s...", report.String())
+	if err := report.WriteToFile(outFile); err != nil {
+		return err
+	}
+	// orphans of scanned regions are saved, failed regions are reported after that
+	return findErr
+}
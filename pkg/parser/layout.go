@@ -0,0 +1,175 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultPathTemplate is the default C7N output layout
+const DefaultPathTemplate = "{account}/{region}/{policy}/resources.json"
+
+// Region of the resource files if the template has no region placeholder
+const defaultRegion = "global"
+
+var placeholderRegexp = regexp.MustCompile(`\{([a-z]+)\}`)
+
+// C7N custodian run log file name written next to resources.json
+const runLogFile = "custodian-run.log"
+
+// C7N resources.json file location parsed with the path template
+type reportFile struct {
+	path string
+	// False if the run directory has only the run log, e.g. C7N failed before writing resources
+	hasResources bool
+	account      string
+	region       string
+	policy       string
+	// Values of all date placeholders joined with `/`, runs are ordered by the date as strings
+	date    string
+	modTime time.Time
+}
+
+// Path template with {account}, {region}, {date} and {policy} placeholders matched to the end of the file path,
+// {date} can be used several times for date partitioned directories, e.g. `{date}/{date}/{date}`
+type layout struct {
+	regexp   *regexp.Regexp
+	fileName string
+}
+
+func newLayout(template string) (*layout, error) {
+	if template == "" {
+		template = DefaultPathTemplate
+	}
+	var (
+		pattern strings.Builder
+		last    int
+		dates   int
+	)
+	found := make(map[string]bool)
+	for _, m := range placeholderRegexp.FindAllStringSubmatchIndex(template, -1) {
+		pattern.WriteString(regexp.QuoteMeta(template[last:m[0]]))
+		name := template[m[2]:m[3]]
+		switch name {
+		case "account", "region", "policy":
+			if found[name] {
+				return nil, fmt.Errorf("placeholder {%s} is used more than once", name)
+			}
+			pattern.WriteString(fmt.Sprintf("(?P<%s>[^/]+)", name))
+		case "date":
+			pattern.WriteString(fmt.Sprintf("(?P<date%d>[^/]+)", dates))
+			dates++
+		default:
+			return nil, fmt.Errorf("unknown placeholder {%s}", name)
+		}
+		found[name] = true
+		last = m[1]
+	}
+	pattern.WriteString(regexp.QuoteMeta(template[last:]))
+	if !found["account"] || !found["policy"] {
+		return nil, errors.New("path template must contain {account} and {policy} placeholders")
+	}
+	re, err := regexp.Compile("(?:^|/)" + pattern.String() + "$")
+	if err != nil {
+		return nil, err
+	}
+	return &layout{regexp: re, fileName: filepath.Base(template)}, nil
+}
+
+// Parses the file path relative to the report directory, returns false if it doesn't match the template
+func (l *layout) parse(relPath string) (reportFile, bool) {
+	match := l.regexp.FindStringSubmatch(filepath.ToSlash(relPath))
+	if match == nil {
+		return reportFile{}, false
+	}
+	file := reportFile{region: defaultRegion}
+	dates := make([]string, 0)
+	for i, name := range l.regexp.SubexpNames() {
+		switch {
+		case name == "account":
+			file.account = match[i]
+		case name == "region":
+			file.region = match[i]
+		case name == "policy":
+			file.policy = match[i]
+		case strings.HasPrefix(name, "date"):
+			dates = append(dates, match[i])
+		}
+	}
+	file.date = strings.Join(dates, "/")
+	return file, true
+}
+
+// Returns C7N resource files grouped by policy, only the newest run (by date placeholders, then modification time)
+// of each account and region is returned. All policies are returned if the policy is empty.
+func resourceFiles(c7nDir, policy string, l *layout) (map[string][]reportFile, error) {
+	newest := make(map[string]reportFile)
+	err := filepath.Walk(c7nDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		relPath, err := filepath.Rel(c7nDir, path)
+		if err != nil {
+			return err
+		}
+		hasResources := true
+		if filepath.Base(path) == runLogFile {
+			// run without resources file is matched as if the file exists
+			relPath = filepath.Join(filepath.Dir(relPath), l.fileName)
+			path = filepath.Join(filepath.Dir(path), l.fileName)
+			hasResources = false
+		}
+		file, ok := l.parse(relPath)
+		if !ok || (policy != "" && file.policy != policy) {
+			return nil
+		}
+		file.path, file.modTime, file.hasResources = path, info.ModTime(), hasResources
+		key := strings.Join([]string{file.policy, file.account, file.region}, "/")
+		current, ok := newest[key]
+		switch {
+		case !ok || isNewer(file, current):
+			newest[key] = file
+		case current.path == file.path:
+			// run log and resources of the same run
+			current.hasResources = current.hasResources || file.hasResources
+			newest[key] = current
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string][]reportFile)
+	for _, key := range keys(newest) {
+		file := newest[key]
+		files[file.policy] = append(files[file.policy], file)
+	}
+	return files, nil
+}
+
+func isNewer(file, current reportFile) bool {
+	if file.date != current.date {
+		return file.date > current.date
+	}
+	if file.path == current.path {
+		return false
+	}
+	return file.modTime.After(current.modTime)
+}
+
+func keys[V any](m map[string]V) []string {
+	result := make([]string, 0, len(m))
+	for k := range m {
+		result = append(result, k)
+	}
+	sort.Strings(result)
+	return result
+}
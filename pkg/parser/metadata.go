@@ -0,0 +1,120 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"c7n-helper/pkg/definition"
+)
+
+// C7N resources of the built-in types, the first type of the resource is used if the type is not set explicitly
+var typeResources = map[string][]string{
+	"eks":     {"aws.eks"},
+	"ec2":     {"aws.ec2"},
+	"s3":      {"aws.s3"},
+	"k8s-ec2": {"aws.ec2", "aws.vpc"},
+	"gke":     {"gcp.gke-cluster"},
+	"gce":     {"gcp.instance"},
+	"arg":     {"azure.resourcegroup"},
+}
+
+var resourceTypes = map[string]string{
+	"aws.eks":             "eks",
+	"aws.ec2":             "ec2",
+	"aws.s3":              "s3",
+	"aws.vpc":             "k8s-ec2",
+	"gcp.gke-cluster":     "gke",
+	"gcp.instance":        "gce",
+	"azure.resourcegroup": "arg",
+}
+
+var errNoMetadata = errors.New("metadata.json not found")
+
+// Reads policy resource from C7N metadata.json next to resources.json
+func policyResource(resourcesFile string) (string, error) {
+	content, err := os.ReadFile(filepath.Join(filepath.Dir(resourcesFile), "metadata.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", errNoMetadata
+	}
+	if err != nil {
+		return "", err
+	}
+	var metadata struct {
+		Policy struct {
+			Resource string `json:"resource"`
+		} `json:"policy"`
+	}
+	if err := json.Unmarshal(content, &metadata); err != nil {
+		return "", err
+	}
+	return normalizeResource(metadata.Policy.Resource), nil
+}
+
+// Old C7N versions write AWS resources without provider prefix
+func normalizeResource(resource string) string {
+	resource = strings.ToLower(resource)
+	if resource != "" && !strings.Contains(resource, ".") {
+		return "aws." + resource
+	}
+	return resource
+}
+
+// Infers the helper type from policy metadata files or checks the explicit type matches the metadata.
+// Files with unreadable metadata are returned by path and don't take part in the type inference.
+func policyType(resourceType string, files []reportFile, definitions definition.Definitions) (string, map[string]error, error) {
+	resources := make(map[string]struct{})
+	failed := make(map[string]error)
+	for _, file := range files {
+		resource, err := policyResource(file.path)
+		if errors.Is(err, errNoMetadata) {
+			continue
+		}
+		if err != nil {
+			failed[file.path] = fmt.Errorf("metadata.json: %w", err)
+			continue
+		}
+		resources[resource] = struct{}{}
+	}
+	if len(resources) == 0 {
+		if resourceType == "" {
+			return "", failed, errors.New("resource type can't be inferred without metadata.json, set the type explicitly")
+		}
+		return resourceType, failed, nil
+	}
+	if len(resources) > 1 {
+		return "", failed, fmt.Errorf("policy has different resources in metadata: %s", strings.Join(keys(resources), ", "))
+	}
+	var resource string
+	for r := range resources {
+		resource = r
+	}
+	if resourceType == "" {
+		if t, ok := resourceTypes[resource]; ok {
+			return t, failed, nil
+		}
+		for name, d := range definitions.Types {
+			if normalizeResource(d.Resource) == resource {
+				return name, failed, nil
+			}
+		}
+		return "", failed, fmt.Errorf("unsupported c7n resource %s", resource)
+	}
+	accepted, ok := typeResources[resourceType]
+	if d, found := definitions.Types[resourceType]; found && d.Resource != "" {
+		accepted, ok = []string{normalizeResource(d.Resource)}, true
+	}
+	if !ok {
+		// resource of the definition is not set, nothing to check
+		return resourceType, failed, nil
+	}
+	for _, r := range accepted {
+		if r == resource {
+			return resourceType, failed, nil
+		}
+	}
+	return "", failed, fmt.Errorf("type %s doesn't match c7n resource %s from metadata", resourceType, resource)
+}
@@ -1,133 +1,395 @@
 package parser
 
 import (
+	"bufio"
 	"context"
-	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strings"
+	"sync"
 
 	"c7n-helper/pkg/aws"
 	"c7n-helper/pkg/azure"
+	"c7n-helper/pkg/defaults"
+	"c7n-helper/pkg/definition"
 	"c7n-helper/pkg/dto"
+	"c7n-helper/pkg/expiry"
 	"c7n-helper/pkg/gcp"
 	"c7n-helper/pkg/log"
+	"c7n-helper/pkg/tagschema"
 )
 
-var resourceParsers = map[string]func(region string, content []byte) ([]dto.Resource, error){
-	"eks": aws.ParseEKS,
-	"ec2": aws.ParseEC2,
-	"s3":  aws.ParseS3,
-	"gke": gcp.GKE,
-	"gce": gcp.GCE,
-	"arg": azure.RG,
+type resourceParser func(region string, r io.Reader) ([]dto.Resource, error)
+
+// Built-in parser reads owner, expiry and other tags by the tag schema of its cloud
+type builtinParser func(region string, r io.Reader, schema tagschema.Config) ([]dto.Resource, error)
+
+var resourceParsers = map[string]builtinParser{
+	"eks":     aws.ParseEKS,
+	"ec2":     aws.ParseEC2,
+	"s3":      aws.ParseS3,
+	"k8s-ec2": aws.ParseK8sEC2,
+	"gke":     gcp.GKE,
+	"gce":     gcp.GCE,
+	"arg":     azure.RG,
+}
+
+// Modes of handling unreadable or malformed C7N resource files
+const (
+	// OnErrorFail aborts parsing
+	OnErrorFail = "fail"
+	// OnErrorSkip logs the error and continues
+	OnErrorSkip = "skip"
+	// OnErrorRecord saves the error to the report diagnostics and continues
+	OnErrorRecord = "record"
+)
+
+type Options struct {
+	// Inferred from C7N metadata.json if empty
+	Type      string
+	ReportDir string
+	// All policies are parsed if empty
+	Policy       string
+	ResourceFile string
+	// Resource types parsed along with the built-in ones
+	DefinitionsFile string
+	// The report is signed if set
+	SigningKey []byte
+	// C7N output layout, DefaultPathTemplate if empty
+	PathTemplate string
+	// Saves all policies to one multi-section resource file,
+	// otherwise each policy is saved to `<resource-file-name>-<policy>.json` file if the policy is empty
+	Combined bool
+	// Tag keys of owner, expiry and other fields, tagschema.Default if empty
+	TagSchemaFile string
+	// Default TTL of resources without expiry tag, defaults.Default if empty
+	DefaultsFile string
+	// OnErrorFail if empty
+	OnError string
+	// Parse fails after saving the reports if the ratio of failed resource files is greater
+	MaxErrorRatio float64
+	// Number of resource files parsed concurrently, number of CPUs if not positive
+	Workers int
 }
 
-func Parse(ctx context.Context, resourceType, c7nDir, policy, outFile string) error {
+// Counts of parsed and failed C7N resource files
+type fileStats struct {
+	total, failed int
+}
+
+// Result of parsing one C7N resource file
+type fileResult struct {
+	resources []dto.Resource
+	run       dto.Run
+	err       error
+}
+
+// Parse converts C7N report directory to the resource file
+func Parse(ctx context.Context, opts Options) error {
 	logger := log.FromContext(ctx)
-	logger.Info("processing c7n report directory...")
-	files, err := resourceFiles(c7nDir, policy)
+	definitions, err := loadDefinitions(ctx, opts.DefinitionsFile)
 	if err != nil {
 		return err
 	}
-	logger.Info("parsing c7n resource files...")
-	report, err := reportFromFiles(ctx, files, resourceType, policy)
+	schema, err := tagschema.Load(opts.TagSchemaFile)
 	if err != nil {
 		return err
 	}
-	logger.Info("sorting resources...")
-	sortResources(report.Accounts)
-	logger.Infof("saving %s...", report.String())
-	return persistReport(report, outFile)
-}
-
-func resourceFiles(c7nDir, policy string) ([]string, error) {
-	files := make([]string, 0)
-	err := filepath.Walk(c7nDir, func(path string, info os.FileInfo, err error) error {
+	defaultsPolicy, err := defaults.Load(opts.DefaultsFile)
+	if err != nil {
+		return err
+	}
+	if err := validateErrorMode(opts); err != nil {
+		return err
+	}
+	l, err := newLayout(opts.PathTemplate)
+	if err != nil {
+		return err
+	}
+	logger.Info("processing c7n report directory...")
+	policyFiles, err := resourceFiles(opts.ReportDir, opts.Policy, l)
+	if err != nil {
+		return err
+	}
+	if len(policyFiles) == 0 {
+		if opts.Policy == "" {
+			return errors.New("no c7n resource files found")
+		}
+		policyFiles[opts.Policy] = nil
+	}
+	combined := dto.Report{Sections: make([]dto.PolicyReport, 0, len(policyFiles))}
+	var stats fileStats
+	for _, name := range keys(policyFiles) {
+		ctx, logger := log.UpdateContext(ctx, "policy", name)
+		report, policyStats, err := policyReport(ctx, opts, definitions, schema, defaultsPolicy, name, policyFiles[name])
 		if err != nil {
+			return fmt.Errorf("policy %s: %w", name, err)
+		}
+		stats.total += policyStats.total
+		stats.failed += policyStats.failed
+		if opts.Combined {
+			combined.Sections = append(combined.Sections, report)
+			continue
+		}
+		file := opts.ResourceFile
+		if opts.Policy == "" {
+			ext := filepath.Ext(file)
+			file = strings.TrimSuffix(file, ext) + "-" + name + ext
+		}
+		logger.Infof("saving %s to %s...", report.String(), file)
+		if err := report.WriteToFile(file); err != nil {
 			return err
 		}
-		if !info.IsDir() && strings.HasSuffix(path, "/"+policy+"/resources.json") {
-			files = append(files, path)
+	}
+	if opts.Combined {
+		logger.Infof("saving %s...", combined.String())
+		if err := combined.WriteToFile(opts.ResourceFile); err != nil {
+			return err
 		}
+	}
+	return checkErrorRatio(ctx, stats, opts.MaxErrorRatio)
+}
+
+func validateErrorMode(opts Options) error {
+	switch opts.OnError {
+	case "", OnErrorFail, OnErrorSkip, OnErrorRecord:
+	default:
+		return fmt.Errorf("unsupported error mode %s, must be %s, %s or %s", opts.OnError, OnErrorFail, OnErrorSkip, OnErrorRecord)
+	}
+	if opts.MaxErrorRatio < 0 || opts.MaxErrorRatio > 1 {
+		return errors.New("max error ratio must be between 0 and 1")
+	}
+	return nil
+}
+
+func checkErrorRatio(ctx context.Context, stats fileStats, maxRatio float64) error {
+	if stats.failed == 0 {
 		return nil
-	})
-	return files, err
+	}
+	ratio := float64(stats.failed) / float64(stats.total)
+	log.FromContext(ctx).Warnf("%d of %d c7n resource files failed to parse", stats.failed, stats.total)
+	if ratio > maxRatio {
+		return fmt.Errorf("error ratio %.2f is greater than %.2f", ratio, maxRatio)
+	}
+	return nil
+}
+
+func policyReport(ctx context.Context, opts Options, definitions definition.Definitions, schema tagschema.Config, defaultsPolicy defaults.Policy, policy string, files []reportFile) (dto.PolicyReport, fileStats, error) {
+	logger := log.FromContext(ctx)
+	reportType, metadataErrs, err := policyType(opts.Type, files, definitions)
+	if len(metadataErrs) > 0 && (opts.OnError == "" || opts.OnError == OnErrorFail) {
+		for _, file := range files {
+			if metadataErr, ok := metadataErrs[file.path]; ok {
+				return dto.PolicyReport{}, fileStats{}, fmt.Errorf("%s: %w", file.path, metadataErr)
+			}
+		}
+	}
+	if err != nil {
+		return dto.PolicyReport{}, fileStats{}, err
+	}
+	parser, err := findParser(reportType, definitions, schema)
+	if err != nil {
+		return dto.PolicyReport{}, fileStats{}, err
+	}
+	logger.Infof("parsing c7n resource files of %s type...", reportType)
+	report, stats, err := reportFromFiles(ctx, files, metadataErrs, parser, reportType, policy, opts)
+	if err != nil {
+		return dto.PolicyReport{}, fileStats{}, err
+	}
+	applyDefaults(report, defaultsPolicy)
+	logger.Info("sorting resources...")
+	sortResources(report.Accounts)
+	if invalid := countInvalidExpiries(report.Accounts); invalid > 0 {
+		logger.Warnf("resources with invalid expiry: %d", invalid)
+	}
+	if len(opts.SigningKey) > 0 {
+		logger.Info("signing report...")
+		if err := report.Sign(opts.SigningKey); err != nil {
+			return dto.PolicyReport{}, fileStats{}, err
+		}
+	}
+	return report, stats, nil
 }
 
-func reportFromFiles(ctx context.Context, files []string, resourceType, policy string) (dto.PolicyReport, error) {
+// Parses C7N resource files, failed files and files with unreadable metadata are handled according to the error mode.
+// Results are merged in the files order, so the report doesn't depend on the parsing order.
+func reportFromFiles(ctx context.Context, files []reportFile, metadataErrs map[string]error, parser resourceParser, resourceType, policy string, opts Options) (dto.PolicyReport, fileStats, error) {
 	accountMap := make(map[string]dto.Account)
-	for _, file := range files {
-		accName, region := accountRegion(file)
-		resources, err := resourcesFromFile(ctx, resourceType, region, file)
-		if err != nil {
-			return dto.PolicyReport{}, err
+	runs := make([]dto.Run, 0, len(files))
+	var diagnostics []dto.Diagnostic
+	var stats fileStats
+	for i, result := range parseFiles(ctx, files, parser, opts.Workers) {
+		file := files[i]
+		runs = append(runs, result.run)
+		if !file.hasResources {
+			continue
+		}
+		stats.total++
+		fileErr := result.err
+		if metadataErr, ok := metadataErrs[file.path]; ok {
+			fileErr = metadataErr
 		}
-		if len(resources) == 0 {
+		if fileErr != nil {
+			if opts.OnError == "" || opts.OnError == OnErrorFail {
+				return dto.PolicyReport{}, stats, fmt.Errorf("%s: %w", file.path, fileErr)
+			}
+			stats.failed++
+			log.FromContext(ctx).Warnf("skipping %s: %s", file.path, fileErr.Error())
+			if opts.OnError == OnErrorRecord {
+				diagnostics = append(diagnostics, dto.Diagnostic{Account: file.account, Region: file.region, File: file.path, Error: fileErr.Error()})
+			}
 			continue
 		}
-		account, ok := accountMap[accName]
+		if len(result.resources) == 0 {
+			continue
+		}
+		account, ok := accountMap[file.account]
 		if !ok {
-			account = dto.Account{Name: accName, Resources: make([]dto.Resource, 0)}
+			account = dto.Account{Name: file.account, Resources: make([]dto.Resource, 0)}
 		}
-		account.Resources = append(account.Resources, resources...)
-		accountMap[accName] = account
+		account.Resources = append(account.Resources, result.resources...)
+		accountMap[file.account] = account
 	}
 	return dto.PolicyReport{
-		Type:     resourceType,
-		Policy:   policy,
-		Accounts: accountsFromMap(accountMap),
-	}, nil
+		Type:        resourceType,
+		Policy:      policy,
+		Accounts:    accountsFromMap(accountMap),
+		Runs:        runs,
+		Diagnostics: diagnostics,
+	}, stats, nil
+}
+
+// Parses resource files and reads their runs by the pool of workers, results have the files order
+func parseFiles(ctx context.Context, files []reportFile, parser resourceParser, workers int) []fileResult {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	results := make([]fileResult, len(files))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, len(files)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = parseFile(ctx, files[i], parser)
+			}
+		}()
+	}
+	for i := range files {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
+
+func parseFile(ctx context.Context, file reportFile, parser resourceParser) fileResult {
+	if !file.hasResources {
+		return fileResult{run: readRun(file, 0)}
+	}
+	resources, err := resourcesFromFile(ctx, parser, file.region, file.path)
+	if err != nil {
+		run := readRun(file, 0)
+		if run.Failure != "" {
+			run.Failure += ", "
+		}
+		run.Failure += "resources.json is malformed"
+		return fileResult{run: run, err: err}
+	}
+	return fileResult{resources: resources, run: readRun(file, len(resources))}
 }
 
 func sortResources(accounts []dto.Account) {
 	for i := range accounts {
 		acc := accounts[i]
-		sort.Slice(acc.Resources, func(i, j int) bool {
-			return acc.Resources[i].Created.Before(acc.Resources[j].Created)
+		sort.SliceStable(acc.Resources, func(i, j int) bool {
+			a, b := acc.Resources[i], acc.Resources[j]
+			if !a.Created.Equal(b.Created) {
+				return a.Created.Before(b.Created)
+			}
+			if a.Location != b.Location {
+				return a.Location < b.Location
+			}
+			if a.Name != b.Name {
+				return a.Name < b.Name
+			}
+			return a.ID < b.ID
 		})
 	}
 }
 
-func resourcesFromFile(ctx context.Context, resourceType, region, file string) ([]dto.Resource, error) {
-	parser, ok := resourceParsers[resourceType]
-	if !ok {
-		return nil, errors.New("unsupported resource type")
-	}
-	content, err := jsonToBytes(ctx, file)
-	if err != nil {
-		return nil, err
+// Sets expiry of resources with missing or invalid expiry tag to created time plus the default TTL,
+// missing expiry is marked as defaulted and invalid is kept to be reported
+func applyDefaults(report dto.PolicyReport, policy defaults.Policy) {
+	for _, account := range report.Accounts {
+		for i := range account.Resources {
+			resource := &account.Resources[i]
+			if (resource.ExpiryStatus != expiry.Missing && resource.ExpiryStatus != expiry.Invalid) || resource.Created.IsZero() {
+				continue
+			}
+			ttl, ok := policy.TTL(report.Type, account.Name, resource.Owner != "")
+			if !ok {
+				continue
+			}
+			resource.Expiry = resource.Created.Add(ttl)
+			if resource.ExpiryStatus == expiry.Missing {
+				resource.ExpiryStatus = expiry.Defaulted
+			}
+		}
 	}
-	return parser(region, content)
 }
 
-func accountsFromMap(accountMap map[string]dto.Account) []dto.Account {
-	accounts := make([]dto.Account, 0, len(accountMap))
-	for _, accRegion := range accountMap {
-		accounts = append(accounts, accRegion)
+func countInvalidExpiries(accounts []dto.Account) int {
+	count := 0
+	for _, account := range accounts {
+		for _, resource := range account.Resources {
+			if resource.ExpiryStatus == expiry.Invalid {
+				count++
+			}
+		}
 	}
-	return accounts
+	return count
 }
 
-func persistReport(report dto.PolicyReport, outFile string) error {
-	file, err := json.MarshalIndent(report, "", " ")
+func loadDefinitions(ctx context.Context, definitionsFile string) (definition.Definitions, error) {
+	if definitionsFile == "" {
+		return definition.Definitions{}, nil
+	}
+	log.FromContext(ctx).Info("reading resource type definitions...")
+	definitions, err := definition.Load(definitionsFile)
 	if err != nil {
-		return err
+		return definitions, err
+	}
+	for name := range definitions.Types {
+		if _, ok := resourceParsers[name]; ok {
+			return definitions, fmt.Errorf("definition %s conflicts with built-in resource type", name)
+		}
 	}
-	return os.WriteFile(outFile, file, 0644)
+	return definitions, nil
 }
 
-// Parses C7N report path: `.../<account-name|project|subscription>/<region-name|global>/<policy-name>/resources.json`
-func accountRegion(file string) (string, string) {
-	parts := strings.Split(file, "/")
-	l := len(parts)
-	return parts[l-4] /* account */, parts[l-3] /* region */
+// Returns built-in parser with the tag schema or parser from the definitions
+func findParser(resourceType string, definitions definition.Definitions, schema tagschema.Config) (resourceParser, error) {
+	if parser, ok := resourceParsers[resourceType]; ok {
+		return func(region string, r io.Reader) ([]dto.Resource, error) {
+			return parser(region, r, schema)
+		}, nil
+	}
+	if d, ok := definitions.Types[resourceType]; ok {
+		return func(region string, r io.Reader) ([]dto.Resource, error) {
+			return d.Parse(region, r, schema)
+		}, nil
+	}
+	return nil, errors.New("unsupported resource type")
 }
 
-func jsonToBytes(ctx context.Context, file string) ([]byte, error) {
+func resourcesFromFile(ctx context.Context, parser resourceParser, region, file string) ([]dto.Resource, error) {
 	jsonFile, err := os.Open(file)
 	if err != nil {
 		return nil, err
@@ -137,5 +399,14 @@ func jsonToBytes(ctx context.Context, file string) ([]byte, error) {
 			log.FromContext(ctx).Errorf("unable to close json file: %s", err.Error())
 		}
 	}()
-	return io.ReadAll(jsonFile)
+	return parser(region, bufio.NewReader(jsonFile))
+}
+
+// Returns accounts sorted by name
+func accountsFromMap(accountMap map[string]dto.Account) []dto.Account {
+	accounts := make([]dto.Account, 0, len(accountMap))
+	for _, name := range keys(accountMap) {
+		accounts = append(accounts, accountMap[name])
+	}
+	return accounts
 }
@@ -0,0 +1,279 @@
+package parser_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"c7n-helper/pkg/dto"
+	"c7n-helper/pkg/expiry"
+	"c7n-helper/pkg/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func writePolicy(t *testing.T, dir, account, region, policy, resource, resources string) {
+	writeResources(t, filepath.Join(dir, account, region, policy), policy, resource, resources)
+}
+
+func writeResources(t *testing.T, path, policy, resource, resources string) {
+	assert.NoError(t, os.MkdirAll(path, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(path, "resources.json"), []byte(resources), 0644))
+	metadata := `{"policy": {"name": "` + policy + `", "resource": "` + resource + `"}}`
+	assert.NoError(t, os.WriteFile(filepath.Join(path, "metadata.json"), []byte(metadata), 0644))
+}
+
+func TestParseInfersType(t *testing.T) {
+	dir := t.TempDir()
+	writePolicy(t, dir, "dev", "us-east-1", "expired-eks", "aws.eks",
+		`[{"name": "cluster-1", "createdAt": "2024-05-01T10:00:00Z", "tags": {"owner": "alice"}}]`)
+	writePolicy(t, dir, "dev", "global", "expired-rg", "azure.resourcegroup",
+		`[{"name": "rg-1", "location": "westeurope", "tags": {"owner": "bob"}}]`)
+	outDir := t.TempDir()
+	out := filepath.Join(outDir, "resources.json")
+
+	assert.NoError(t, parser.Parse(context.Background(), parser.Options{ReportDir: dir, ResourceFile: out}))
+	var report dto.PolicyReport
+	assert.NoError(t, report.ReadFromFile(filepath.Join(outDir, "resources-expired-eks.json")))
+	assert.Equal(t, "eks", report.Type)
+	assert.Equal(t, "cluster-1", report.Accounts[0].Resources[0].Name)
+	assert.NoError(t, report.ReadFromFile(filepath.Join(outDir, "resources-expired-rg.json")))
+	assert.Equal(t, "arg", report.Type)
+
+	assert.NoError(t, parser.Parse(context.Background(), parser.Options{ReportDir: dir, Policy: "expired-eks", ResourceFile: out}))
+	assert.NoError(t, report.ReadFromFile(out))
+	assert.Equal(t, "expired-eks", report.Policy)
+
+	assert.Error(t, parser.Parse(context.Background(), parser.Options{Type: "gke", ReportDir: dir, Policy: "expired-eks", ResourceFile: out}))
+
+	assert.NoError(t, parser.Parse(context.Background(), parser.Options{ReportDir: dir, ResourceFile: out, Combined: true}))
+	var combined dto.Report
+	assert.NoError(t, combined.ReadFromFile(out))
+	assert.Len(t, combined.Sections, 2)
+	assert.Equal(t, "eks", combined.Sections[0].Type)
+	assert.Equal(t, "arg", combined.Sections[1].Type)
+}
+
+func TestParsePathTemplate(t *testing.T) {
+	dir := t.TempDir()
+	eks := func(name string) string {
+		return `[{"name": "` + name + `", "createdAt": "2024-05-01T10:00:00Z"}]`
+	}
+	writeResources(t, filepath.Join(dir, "mirror", "dev", "us-east-1", "2024", "05", "01", "expired-eks"), "expired-eks", "aws.eks", eks("old"))
+	writeResources(t, filepath.Join(dir, "mirror", "dev", "us-east-1", "2024", "05", "02", "expired-eks"), "expired-eks", "aws.eks", eks("new"))
+	writeResources(t, filepath.Join(dir, "mirror", "dev", "eu-west-1", "2024", "04", "30", "expired-eks"), "expired-eks", "aws.eks", eks("other"))
+	out := filepath.Join(t.TempDir(), "resources.json")
+
+	opts := parser.Options{
+		ReportDir:    dir,
+		Policy:       "expired-eks",
+		ResourceFile: out,
+		PathTemplate: "{account}/{region}/{date}/{date}/{date}/{policy}/resources.json",
+	}
+	assert.NoError(t, parser.Parse(context.Background(), opts))
+	var report dto.PolicyReport
+	assert.NoError(t, report.ReadFromFile(out))
+	assert.Len(t, report.Accounts, 1)
+	names := make([]string, 0)
+	for _, r := range report.Accounts[0].Resources {
+		names = append(names, r.Location+"/"+r.Name)
+	}
+	assert.ElementsMatch(t, []string{"us-east-1/new", "eu-west-1/other"}, names)
+
+	opts.PathTemplate = "{account}/{unknown}/{policy}/resources.json"
+	assert.Error(t, parser.Parse(context.Background(), opts))
+}
+
+func TestParseOnError(t *testing.T) {
+	dir := t.TempDir()
+	writePolicy(t, dir, "dev", "us-east-1", "expired-eks", "aws.eks", `[{"name": "cluster-1", "createdAt": "2024-05-01T10:00:00Z"}]`)
+	writePolicy(t, dir, "dev", "eu-west-1", "expired-eks", "aws.eks", `[{"name": `)
+	out := filepath.Join(t.TempDir(), "resources.json")
+	opts := parser.Options{ReportDir: dir, Policy: "expired-eks", ResourceFile: out}
+
+	assert.Error(t, parser.Parse(context.Background(), opts))
+
+	opts.OnError = parser.OnErrorRecord
+	assert.Error(t, parser.Parse(context.Background(), opts))
+	var report dto.PolicyReport
+	assert.NoError(t, report.ReadFromFile(out))
+	assert.Equal(t, "cluster-1", report.Accounts[0].Resources[0].Name)
+	assert.Len(t, report.Diagnostics, 1)
+	assert.Equal(t, "eu-west-1", report.Diagnostics[0].Region)
+
+	opts.MaxErrorRatio = 0.5
+	assert.NoError(t, parser.Parse(context.Background(), opts))
+
+	opts.OnError = parser.OnErrorSkip
+	assert.NoError(t, parser.Parse(context.Background(), opts))
+	var skipped dto.PolicyReport
+	assert.NoError(t, skipped.ReadFromFile(out))
+	assert.Empty(t, skipped.Diagnostics)
+
+	opts.OnError = "ignore"
+	assert.Error(t, parser.Parse(context.Background(), opts))
+}
+
+func TestParseOnErrorMetadata(t *testing.T) {
+	dir := t.TempDir()
+	writePolicy(t, dir, "dev", "us-east-1", "expired-eks", "aws.eks", `[{"name": "cluster-1", "createdAt": "2024-05-01T10:00:00Z"}]`)
+	writePolicy(t, dir, "dev", "eu-west-1", "expired-eks", "aws.eks", `[{"name": "cluster-2", "createdAt": "2024-05-01T10:00:00Z"}]`)
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "dev", "eu-west-1", "expired-eks", "metadata.json"), []byte(`{"policy": `), 0644))
+	out := filepath.Join(t.TempDir(), "resources.json")
+	opts := parser.Options{ReportDir: dir, Policy: "expired-eks", ResourceFile: out}
+
+	assert.Error(t, parser.Parse(context.Background(), opts))
+
+	opts.OnError = parser.OnErrorRecord
+	opts.MaxErrorRatio = 0.5
+	assert.NoError(t, parser.Parse(context.Background(), opts))
+	var report dto.PolicyReport
+	assert.NoError(t, report.ReadFromFile(out))
+	assert.Equal(t, "eks", report.Type)
+	assert.Len(t, report.Accounts[0].Resources, 1)
+	assert.Equal(t, "cluster-1", report.Accounts[0].Resources[0].Name)
+	assert.Len(t, report.Diagnostics, 1)
+	assert.Equal(t, "eu-west-1", report.Diagnostics[0].Region)
+	assert.Contains(t, report.Diagnostics[0].Error, "metadata.json")
+
+	opts.OnError = parser.OnErrorSkip
+	assert.NoError(t, parser.Parse(context.Background(), opts))
+	var skipped dto.PolicyReport
+	assert.NoError(t, skipped.ReadFromFile(out))
+	assert.Len(t, skipped.Accounts[0].Resources, 1)
+	assert.Empty(t, skipped.Diagnostics)
+}
+
+func TestParseDeterministic(t *testing.T) {
+	dir := t.TempDir()
+	for _, account := range []string{"prod", "dev", "stage"} {
+		for _, region := range []string{"us-east-1", "eu-west-1"} {
+			writePolicy(t, dir, account, region, "expired-eks", "aws.eks",
+				`[{"name": "b", "createdAt": "2024-05-01T10:00:00Z", "tags": {"expiry": "2024-06-01"}}, {"name": "a", "createdAt": "2024-05-01T10:00:00Z", "tags": {"expiry": "2024-06-01"}}]`)
+		}
+	}
+	outDir := t.TempDir()
+	read := func(workers int) []byte {
+		out := filepath.Join(outDir, "resources.json")
+		assert.NoError(t, parser.Parse(context.Background(), parser.Options{ReportDir: dir, Policy: "expired-eks", ResourceFile: out, Workers: workers}))
+		content, err := os.ReadFile(out)
+		assert.NoError(t, err)
+		return content
+	}
+	content := read(1)
+	assert.Equal(t, content, read(8))
+
+	var report dto.PolicyReport
+	assert.NoError(t, report.ReadFromFile(filepath.Join(outDir, "resources.json")))
+	assert.Equal(t, []string{"dev", "prod", "stage"}, []string{report.Accounts[0].Name, report.Accounts[1].Name, report.Accounts[2].Name})
+	names := make([]string, 0)
+	for _, r := range report.Accounts[0].Resources {
+		names = append(names, r.Location+"/"+r.Name)
+	}
+	assert.Equal(t, []string{"eu-west-1/a", "eu-west-1/b", "us-east-1/a", "us-east-1/b"}, names)
+}
+
+func TestParseDefaults(t *testing.T) {
+	dir := t.TempDir()
+	writePolicy(t, dir, "dev", "us-east-1", "expired-ec2", "aws.ec2", `[
+		{"InstanceId": "i-1", "LaunchTime": "2024-05-01T10:00:00Z"},
+		{"InstanceId": "i-2", "LaunchTime": "2024-05-01T10:00:00Z", "Tags": [{"Key": "owner", "Value": "alice"}]},
+		{"InstanceId": "i-3", "LaunchTime": "2024-05-01T10:00:00Z", "Tags": [{"Key": "expiry", "Value": "soon"}]}
+	]`)
+	defaultsFile := filepath.Join(t.TempDir(), "defaults.yaml")
+	assert.NoError(t, os.WriteFile(defaultsFile, []byte("rules:\n  - type: ec2\n    owner: false\n    ttl: 3d\n"), 0644))
+	out := filepath.Join(t.TempDir(), "resources.json")
+
+	assert.NoError(t, parser.Parse(context.Background(), parser.Options{ReportDir: dir, Policy: "expired-ec2", ResourceFile: out, DefaultsFile: defaultsFile}))
+	var report dto.PolicyReport
+	assert.NoError(t, report.ReadFromFile(out))
+	resources := report.Accounts[0].Resources
+	assert.Equal(t, "i-1", resources[0].ID)
+	assert.Equal(t, time.Date(2024, 5, 4, 10, 0, 0, 0, time.UTC), resources[0].Expiry)
+	assert.Equal(t, expiry.Defaulted, resources[0].ExpiryStatus)
+	assert.Equal(t, expiry.Missing, resources[1].ExpiryStatus)
+	assert.Equal(t, time.Date(2024, 5, 4, 10, 0, 0, 0, time.UTC), resources[2].Expiry)
+	assert.Equal(t, expiry.Invalid, resources[2].ExpiryStatus)
+}
+
+func TestParseDefaultsWithoutCreated(t *testing.T) {
+	dir := t.TempDir()
+	writePolicy(t, dir, "dev", "global", "expired-rg", "azure.resourcegroup", `[
+		{"name": "rg-1", "location": "westeurope"},
+		{"name": "rg-2", "location": "westeurope", "tags": {"expiry": "ttl=7d"}},
+		{"name": "rg-3", "location": "westeurope", "tags": {"created": "2024-05-01"}}
+	]`)
+	defaultsFile := filepath.Join(t.TempDir(), "defaults.yaml")
+	assert.NoError(t, os.WriteFile(defaultsFile, []byte("rules:\n  - type: arg\n    ttl: 3d\n"), 0644))
+	out := filepath.Join(t.TempDir(), "resources.json")
+
+	assert.NoError(t, parser.Parse(context.Background(), parser.Options{ReportDir: dir, Policy: "expired-rg", ResourceFile: out, DefaultsFile: defaultsFile}))
+	var report dto.PolicyReport
+	assert.NoError(t, report.ReadFromFile(out))
+	resources := make(map[string]dto.Resource)
+	for _, r := range report.Accounts[0].Resources {
+		resources[r.Name] = r
+	}
+	// unknown creation time is not replaced by the parse time
+	assert.True(t, resources["rg-1"].Created.IsZero())
+	assert.Equal(t, expiry.Missing, resources["rg-1"].ExpiryStatus)
+	assert.Equal(t, expiry.Invalid, resources["rg-2"].ExpiryStatus)
+	assert.Equal(t, time.Date(2024, 5, 4, 0, 0, 0, 0, time.UTC), resources["rg-3"].Expiry)
+	assert.Equal(t, expiry.Defaulted, resources["rg-3"].ExpiryStatus)
+}
+
+func copyTestdata(t *testing.T, path string, names ...string) {
+	for _, name := range names {
+		content, err := os.ReadFile(filepath.Join("testdata", "run", name))
+		assert.NoError(t, err)
+		assert.NoError(t, os.WriteFile(filepath.Join(path, name), content, 0644))
+	}
+}
+
+func TestParseRuns(t *testing.T) {
+	dir := t.TempDir()
+	// run with metadata and log of c7n, 2 resources in metadata metric, 1 in resources.json
+	writePolicy(t, dir, "dev", "us-east-1", "expired-eks", "aws.eks", `[{"name": "cluster-1", "createdAt": "2024-05-01T10:00:00Z"}]`)
+	copyTestdata(t, filepath.Join(dir, "dev", "us-east-1", "expired-eks"), "metadata.json", "custodian-run.log")
+	// run without log, resource count is taken from resources.json
+	writePolicy(t, dir, "dev", "eu-west-1", "expired-eks", "aws.eks", `[{"name": "cluster-2", "createdAt": "2024-05-01T10:00:00Z"}]`)
+	// run that failed before writing resources.json
+	failed := filepath.Join(dir, "prod", "us-east-1", "expired-eks")
+	assert.NoError(t, os.MkdirAll(failed, 0755))
+	copyTestdata(t, failed, "custodian-run.log")
+	out := filepath.Join(t.TempDir(), "resources.json")
+
+	assert.NoError(t, parser.Parse(context.Background(), parser.Options{Type: "eks", ReportDir: dir, Policy: "expired-eks", ResourceFile: out}))
+	var report dto.PolicyReport
+	assert.NoError(t, report.ReadFromFile(out))
+	runs := make(map[string]dto.Run)
+	for _, run := range report.Runs {
+		runs[run.Account+"/"+run.Region] = run
+	}
+	assert.Len(t, runs, 3)
+
+	run := runs["dev/us-east-1"]
+	assert.Equal(t, time.Date(2024, 5, 1, 10, 0, 0, 250e6, time.UTC), run.Start)
+	assert.Equal(t, time.Date(2024, 5, 1, 10, 0, 42, 750e6, time.UTC), run.End)
+	assert.Equal(t, 42.5, run.DurationSeconds)
+	assert.Equal(t, 2, run.ResourceCount)
+	assert.Equal(t, 4, run.APICalls)
+	assert.Equal(t, 2, run.Errors)
+	assert.Equal(t, []string{
+		"Error while executing policy expired-eks, continuing",
+		"Error while uploading output to s3://c7n-reports",
+	}, run.ErrorMessages)
+	assert.Equal(t, "errors in custodian-run.log", run.Failure)
+
+	run = runs["dev/eu-west-1"]
+	assert.Equal(t, 1, run.ResourceCount)
+	assert.Zero(t, run.Errors)
+	assert.Empty(t, run.Failure)
+
+	run = runs["prod/us-east-1"]
+	assert.Zero(t, run.ResourceCount)
+	assert.Equal(t, 2, run.Errors)
+	assert.Equal(t, "resources.json is missing, metadata.json is missing, errors in custodian-run.log", run.Failure)
+	assert.Len(t, report.Accounts, 1)
+}
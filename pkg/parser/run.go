@@ -0,0 +1,107 @@
+package parser
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"c7n-helper/pkg/dto"
+)
+
+// Maximum number of error messages from custodian-run.log saved in the report
+const maxErrorMessages = 5
+
+// Reads C7N run execution details, resource count is used if metadata has no ResourceCount metric
+func readRun(file reportFile, resourceCount int) dto.Run {
+	run := dto.Run{Account: file.account, Region: file.region, ResourceCount: resourceCount}
+	dir := filepath.Dir(file.path)
+	var failures []string
+	if !file.hasResources {
+		failures = append(failures, "resources.json is missing")
+	}
+	if err := readRunMetadata(filepath.Join(dir, "metadata.json"), &run); err != nil {
+		failures = append(failures, err.Error())
+	}
+	if err := readRunLog(filepath.Join(dir, runLogFile), &run); err != nil && !errors.Is(err, os.ErrNotExist) {
+		failures = append(failures, err.Error())
+	}
+	if run.Errors > 0 {
+		failures = append(failures, "errors in custodian-run.log")
+	}
+	run.Failure = strings.Join(failures, ", ")
+	return run
+}
+
+func readRunMetadata(file string, run *dto.Run) error {
+	content, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return errors.New("metadata.json is missing")
+	}
+	if err != nil {
+		return err
+	}
+	var metadata struct {
+		Execution struct {
+			Start   float64 `json:"start"`
+			EndTime float64 `json:"end_time"`
+		} `json:"execution"`
+		Metrics []struct {
+			MetricName string  `json:"MetricName"`
+			Value      float64 `json:"Value"`
+		} `json:"metrics"`
+		APIStats map[string]int `json:"api-stats"`
+	}
+	if err := json.Unmarshal(content, &metadata); err != nil {
+		return err
+	}
+	run.Start = epochTime(metadata.Execution.Start)
+	run.End = epochTime(metadata.Execution.EndTime)
+	if !run.Start.IsZero() && !run.End.IsZero() {
+		run.DurationSeconds = run.End.Sub(run.Start).Seconds()
+	}
+	for _, metric := range metadata.Metrics {
+		if metric.MetricName == "ResourceCount" {
+			run.ResourceCount = int(metric.Value)
+		}
+	}
+	for _, calls := range metadata.APIStats {
+		run.APICalls += calls
+	}
+	return nil
+}
+
+// Counts error lines of C7N log: `2024-05-01 10:00:00,000 - custodian.policy - ERROR - <message>`
+func readRunLog(file string, run *dto.Run) error {
+	logFile, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = logFile.Close() }()
+	scanner := bufio.NewScanner(logFile)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		i := strings.Index(line, " - ERROR - ")
+		if i < 0 {
+			continue
+		}
+		run.Errors++
+		if len(run.ErrorMessages) < maxErrorMessages {
+			run.ErrorMessages = append(run.ErrorMessages, line[i+len(" - ERROR - "):])
+		}
+	}
+	return scanner.Err()
+}
+
+func epochTime(seconds float64) time.Time {
+	if seconds <= 0 {
+		return time.Time{}
+	}
+	sec, frac := math.Modf(seconds)
+	return time.Unix(int64(sec), int64(frac*1e9)).UTC().Truncate(time.Millisecond)
+}
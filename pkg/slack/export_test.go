@@ -0,0 +1,13 @@
+package slack
+
+import "c7n-helper/pkg/dto"
+
+var RunWarnings = runWarnings
+
+var PrepareSlackMessage = prepareSlackMessage
+
+type DigestGroup = digestGroup
+
+func NewDigestGroup(section, account string, resources []dto.Resource) *digestGroup {
+	return &digestGroup{section: section, account: account, resources: resources}
+}
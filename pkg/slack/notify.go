@@ -5,8 +5,12 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"c7n-helper/pkg/dto"
+	"c7n-helper/pkg/expiry"
 	"c7n-helper/pkg/log"
 	"github.com/lensesio/tableprinter"
 )
@@ -17,16 +21,27 @@ type msgLine struct {
 	Name    string `header:"Name"`
 	Created string `header:"Created date"`
 	Expiry  string `header:"Expiry date"`
+	Delete  string `header:"Deletion date"`
 }
 
-func Notify(ctx context.Context, resourceFile, slackToken, slackDefaultChannel, title string) error {
+// Resources of one report section and account sent to one Slack channel
+type digestGroup struct {
+	section   string
+	account   string
+	resources []dto.Resource
+}
+
+// Notify sends resources digest to owners and C7N run warnings (failed runs, missing expected accounts and regions)
+// to the default channel
+func Notify(ctx context.Context, resourceFile, slackToken, slackDefaultChannel, title string, expectedAccounts, expectedRegions []string) error {
 	logger := log.FromContext(ctx)
 	logger.Info("reading resource file...")
-	var report dto.PolicyReport
+	var report dto.Report
 	if err := report.ReadFromFile(resourceFile); err != nil {
 		return err
 	}
-	if len(report.Accounts) == 0 {
+	warnings := runWarnings(report.Sections, expectedAccounts, expectedRegions)
+	if isEmpty(report) && len(warnings) == 0 {
 		logger.Info("nothing to send")
 		return nil
 	}
@@ -40,50 +55,148 @@ func Notify(ctx context.Context, resourceFile, slackToken, slackDefaultChannel,
 		return err
 	}
 	logger.Info("preparing slack messages...")
-	channelMessages := prepareSlackMessage(title, groupSlackMessage(report.Accounts, slack))
+	channelMessages := prepareSlackMessage(title, groupSlackMessage(report.Sections, slack))
+	if len(warnings) > 0 {
+		logger.Infof("c7n run warnings: %d", len(warnings))
+		channelMessages[slackDefaultChannel] = append(channelMessages[slackDefaultChannel],
+			packMessages(title+" - c7n run warnings", []string{"```\n" + strings.Join(warnings, "\n") + "\n```\n"})...)
+	}
 	logger.Info("sending slack notification...")
 	return slack.notify(ctx, channelMessages)
 }
 
-// Groups Slack messages: SlackChannelID -> Account|Project|Subscription -> []Resources
-func groupSlackMessage(accounts []dto.Account, slack *slackProvider) map[string]map[string][]dto.Resource {
-	groups := make(map[string]map[string][]dto.Resource)
-	for _, account := range accounts {
-		for _, resource := range account.Resources {
-			channel := slack.getSlackIDByOwner(resource.Owner)
-			accountResources, ok := groups[channel]
-			if !ok {
-				accountResources = make(map[string][]dto.Resource)
-				groups[channel] = accountResources
+func isEmpty(report dto.Report) bool {
+	for _, section := range report.Sections {
+		if len(section.Accounts) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns warnings about failed runs, expected accounts without runs and expected regions without runs
+// in each account, sections without runs are skipped
+func runWarnings(sections []dto.PolicyReport, expectedAccounts, expectedRegions []string) []string {
+	warnings := make([]string, 0)
+	for _, section := range sections {
+		if len(section.Runs) == 0 {
+			continue
+		}
+		name := sectionName(section)
+		// account -> regions with runs
+		accounts := make(map[string]map[string]struct{})
+		for _, run := range section.Runs {
+			if _, ok := accounts[run.Account]; !ok {
+				accounts[run.Account] = make(map[string]struct{})
 			}
-			if _, ok := accountResources[account.Name]; !ok {
-				accountResources[account.Name] = make([]dto.Resource, 0)
+			accounts[run.Account][run.Region] = struct{}{}
+			if run.Failure == "" {
+				continue
+			}
+			warning := fmt.Sprintf("%s: %s/%s run failed: %s", name, run.Account, run.Region, run.Failure)
+			if len(run.ErrorMessages) > 0 {
+				warning += fmt.Sprintf(" (%d errors, first: %s)", run.Errors, run.ErrorMessages[0])
+			}
+			warnings = append(warnings, warning)
+		}
+		for _, account := range expectedAccounts {
+			if _, ok := accounts[account]; !ok {
+				warnings = append(warnings, fmt.Sprintf("%s: account %s is missing", name, account))
+			}
+		}
+		accountNames := make([]string, 0, len(accounts))
+		for account := range accounts {
+			accountNames = append(accountNames, account)
+		}
+		sort.Strings(accountNames)
+		for _, account := range accountNames {
+			for _, region := range expectedRegions {
+				if _, ok := accounts[account][region]; !ok {
+					warnings = append(warnings, fmt.Sprintf("%s: region %s is missing in account %s", name, region, account))
+				}
+			}
+		}
+	}
+	return warnings
+}
+
+func sectionName(section dto.PolicyReport) string {
+	if section.Policy == "" {
+		return section.Type
+	}
+	return fmt.Sprintf("%s (%s)", section.Policy, section.Type)
+}
+
+// Groups Slack messages: SlackChannelID -> [Section -> Account|Project|Subscription -> []Resources]
+func groupSlackMessage(sections []dto.PolicyReport, slack *slackProvider) map[string][]*digestGroup {
+	groups := make(map[string][]*digestGroup)
+	for _, section := range sections {
+		name := sectionName(section)
+		for _, account := range section.Accounts {
+			// channel -> group of the section account
+			accountGroups := make(map[string]*digestGroup)
+			for _, resource := range account.Resources {
+				channel := slack.getSlackIDByOwner(resource.Owner)
+				group, ok := accountGroups[channel]
+				if !ok {
+					group = &digestGroup{section: name, account: account.Name, resources: make([]dto.Resource, 0)}
+					accountGroups[channel] = group
+					groups[channel] = append(groups[channel], group)
+				}
+				group.resources = append(group.resources, resource)
 			}
-			accountResources[account.Name] = append(accountResources[account.Name], resource)
 		}
 	}
 	return groups
 }
 
-func prepareSlackMessage(title string, groups map[string]map[string][]dto.Resource) map[string][]string {
+// Combines all groups of the channel in one digest, split into several messages if it's too long
+func prepareSlackMessage(title string, groups map[string][]*digestGroup) map[string][]string {
 	channelMessages := make(map[string][]string)
-	for channel, accountResources := range groups {
-		channelMessages[channel] = make([]string, 0)
-		for account, resources := range accountResources {
-			sort.Slice(resources, func(i, j int) bool {
-				return resources[i].Created.Before(resources[j].Created)
+	for channel, digest := range groups {
+		blocks := make([]string, 0, len(digest))
+		for _, group := range digest {
+			resources := group.resources
+			// oldest first, resources with unknown creation time last
+			sort.SliceStable(resources, func(i, j int) bool {
+				left, right := resources[i].Created, resources[j].Created
+				if left.IsZero() || right.IsZero() {
+					return !left.IsZero() && right.IsZero()
+				}
+				return left.Before(right)
 			})
 			buf := bytes.NewBufferString("")
 			tableprinter.Print(buf, normalizeDTO(resources))
-			for _, message := range splitMessage(buf.String()) {
-				content := fmt.Sprintf("[%s] %s\n```\n%s```\n", account, title, message)
-				channelMessages[channel] = append(channelMessages[channel], content)
+			for _, table := range splitMessage(buf.String()) {
+				blocks = append(blocks, fmt.Sprintf("[%s] %s\n```\n%s```\n", group.account, group.section, table))
 			}
 		}
+		channelMessages[channel] = packMessages(title, blocks)
 	}
 	return channelMessages
 }
 
+// Packs blocks into messages under Slack message length limit, each message starts with the title
+func packMessages(title string, blocks []string) []string {
+	messages := make([]string, 0)
+	header := ""
+	if title != "" {
+		header = title + "\n"
+	}
+	current := header
+	for _, block := range blocks {
+		if current != header && utf8.RuneCountInString(current+block) > maxSlackMessageLength {
+			messages = append(messages, current)
+			current = header
+		}
+		current += block
+	}
+	if current != header {
+		messages = append(messages, current)
+	}
+	return messages
+}
+
 func normalizeDTO(resources []dto.Resource) []msgLine {
 	result := make([]msgLine, 0, len(resources))
 	for i, r := range resources {
@@ -91,9 +204,18 @@ func normalizeDTO(resources []dto.Resource) []msgLine {
 			Index:   i + 1,
 			Region:  r.Location,
 			Name:    r.Name,
-			Created: r.Created.Format("2006-01-02"),
-			Expiry:  r.Expiry.Format("2006-01-02"),
+			Created: formatDate(r.Created),
+			Expiry:  expiry.Format(r.Expiry, r.ExpiryStatus),
+			Delete:  formatDate(r.DeleteAfter),
 		})
 	}
 	return result
 }
+
+// Returns the date or "-" if the time is unknown
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format("2006-01-02")
+}
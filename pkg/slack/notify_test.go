@@ -0,0 +1,60 @@
+package slack_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"c7n-helper/pkg/dto"
+	"c7n-helper/pkg/slack"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunWarnings(t *testing.T) {
+	sections := []dto.PolicyReport{
+		{
+			Type:   "eks",
+			Policy: "expired-eks",
+			Runs: []dto.Run{
+				{Account: "dev", Region: "us-east-1"},
+				{Account: "dev", Region: "eu-west-1", Failure: "errors in custodian-run.log", Errors: 2, ErrorMessages: []string{"access denied"}},
+				// eu-west-1 has a run in dev only, the union of regions would hide the missing prod run
+				{Account: "prod", Region: "us-east-1", Failure: "resources.json is missing"},
+			},
+		},
+		// sections without runs are not checked
+		{Type: "gke"},
+	}
+
+	warnings := slack.RunWarnings(sections, []string{"dev", "prod", "qa"}, []string{"us-east-1", "eu-west-1"})
+	assert.Equal(t, []string{
+		"expired-eks (eks): dev/eu-west-1 run failed: errors in custodian-run.log (2 errors, first: access denied)",
+		"expired-eks (eks): prod/us-east-1 run failed: resources.json is missing",
+		"expired-eks (eks): account qa is missing",
+		"expired-eks (eks): region eu-west-1 is missing in account prod",
+	}, warnings)
+
+	assert.Empty(t, slack.RunWarnings(sections[1:], []string{"dev"}, []string{"us-east-1"}))
+}
+
+func TestPrepareSlackMessageUnknownCreated(t *testing.T) {
+	expiry := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	resources := []dto.Resource{
+		{Name: "unknown", Location: "us-east-1", Expiry: expiry},
+		{Name: "newer", Location: "us-east-1", Created: time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC), Expiry: expiry},
+		{Name: "older", Location: "us-east-1", Created: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), Expiry: expiry},
+	}
+	groups := map[string][]*slack.DigestGroup{"#alerts": {slack.NewDigestGroup("expired-eks", "dev", resources)}}
+
+	messages := slack.PrepareSlackMessage("title", groups)["#alerts"]
+	assert.Len(t, messages, 1)
+	message := messages[0]
+	assert.NotContains(t, message, "0001-01-01")
+	older, newer, unknown := strings.Index(message, "older"), strings.Index(message, "newer"), strings.Index(message, "unknown")
+	assert.True(t, older < newer && newer < unknown, message)
+	for _, line := range strings.Split(message, "\n") {
+		if strings.Contains(line, "unknown") {
+			assert.Contains(t, line, " - ")
+		}
+	}
+}
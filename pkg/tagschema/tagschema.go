@@ -0,0 +1,141 @@
+package tagschema
+
+import (
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Resource fields read from tags, used as keys of the resource sources
+const (
+	Owner   = "owner"
+	Expiry  = "expiry"
+	Created = "created"
+	Name    = "name"
+	Team    = "team"
+)
+
+// Config is YAML file content: tag schema per cloud
+type Config struct {
+	AWS   Schema `yaml:"aws"`
+	GCP   Schema `yaml:"gcp"`
+	Azure Schema `yaml:"azure"`
+}
+
+// Schema lists candidate tag keys (case-insensitive) of each field in priority order
+type Schema struct {
+	Owner   []string `yaml:"owner"`
+	Expiry  []string `yaml:"expiry"`
+	Created []string `yaml:"created"`
+	Name    []string `yaml:"name"`
+	Team    []string `yaml:"team"`
+}
+
+// Values are field values found in tags, sources map the field to the tag key of its value
+type Values struct {
+	Owner   string
+	Expiry  string
+	Created string
+	Name    string
+	Team    string
+	Sources map[string]string
+}
+
+var defaultSchema = Schema{
+	Owner:   []string{"owner"},
+	Expiry:  []string{"expiry", "maid_status", "custodian_status"},
+	Created: []string{"created"},
+	Name:    []string{"name"},
+	Team:    []string{"team"},
+}
+
+// Default returns the same schema for all clouds: `owner`, `expiry` (then C7N mark-for-op `maid_status`
+// and `custodian_status`), `created`, `name` and `team` keys
+func Default() Config {
+	return Config{AWS: defaultSchema, GCP: defaultSchema, Azure: defaultSchema}
+}
+
+// Load reads tag schema YAML file, fields missing in the file have default keys
+func Load(file string) (Config, error) {
+	config := Config{}
+	if file == "" {
+		return Default(), nil
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return config, err
+	}
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return config, err
+	}
+	config.AWS = config.AWS.withDefaults()
+	config.GCP = config.GCP.withDefaults()
+	config.Azure = config.Azure.withDefaults()
+	return config, nil
+}
+
+func (s Schema) withDefaults() Schema {
+	if len(s.Owner) == 0 {
+		s.Owner = defaultSchema.Owner
+	}
+	if len(s.Expiry) == 0 {
+		s.Expiry = defaultSchema.Expiry
+	}
+	if len(s.Created) == 0 {
+		s.Created = defaultSchema.Created
+	}
+	if len(s.Name) == 0 {
+		s.Name = defaultSchema.Name
+	}
+	if len(s.Team) == 0 {
+		s.Team = defaultSchema.Team
+	}
+	return s
+}
+
+// ForResource returns the schema of the C7N resource cloud: `gcp.*` and `azure.*` resources, AWS otherwise
+func (c Config) ForResource(resource string) Schema {
+	switch {
+	case strings.HasPrefix(strings.ToLower(resource), "gcp."):
+		return c.GCP
+	case strings.HasPrefix(strings.ToLower(resource), "azure."):
+		return c.Azure
+	}
+	return c.AWS
+}
+
+// Resolve returns field values from the first found candidate keys
+func (s Schema) Resolve(tags map[string]string) Values {
+	lower := make(map[string]string, len(tags))
+	// lower case key -> tag key, sources record the key of the matched tag
+	tagKeys := make(map[string]string, len(tags))
+	for k, v := range tags {
+		lower[strings.ToLower(k)] = v
+		tagKeys[strings.ToLower(k)] = k
+	}
+	values := Values{Sources: make(map[string]string)}
+	resolve := func(field string, keys []string) string {
+		value, key := First(lower, keys)
+		if key != "" {
+			values.Sources[field] = tagKeys[strings.ToLower(key)]
+		}
+		return value
+	}
+	values.Owner = resolve(Owner, s.Owner)
+	values.Expiry = resolve(Expiry, s.Expiry)
+	values.Created = resolve(Created, s.Created)
+	values.Name = resolve(Name, s.Name)
+	values.Team = resolve(Team, s.Team)
+	return values
+}
+
+// First returns value and key of the first non-empty tag, tags must have lower case keys
+func First(tags map[string]string, keys []string) (string, string) {
+	for _, key := range keys {
+		if value := tags[strings.ToLower(key)]; value != "" {
+			return value, key
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,37 @@
+package tagschema_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"c7n-helper/pkg/tagschema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolve(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "tag-schema.yaml")
+	assert.NoError(t, os.WriteFile(file, []byte("aws:\n  owner: [created-by, Owner]\n  expiry: [ttl, expiry]\n"), 0644))
+	config, err := tagschema.Load(file)
+	assert.NoError(t, err)
+
+	values := config.AWS.Resolve(map[string]string{"owner": "alice", "Created-By": "bob", "Expiry": "2024-06-01", "Team": "platform"})
+	assert.Equal(t, "bob", values.Owner)
+	assert.Equal(t, "2024-06-01", values.Expiry)
+	assert.Equal(t, "platform", values.Team)
+	// sources keep the key of the matched tag
+	assert.Equal(t, map[string]string{"owner": "Created-By", "expiry": "Expiry", "team": "Team"}, values.Sources)
+
+	values = config.GCP.Resolve(map[string]string{"created-by": "bob", "owner": "alice"})
+	assert.Equal(t, "alice", values.Owner)
+}
+
+func TestResolveDefaultExpiry(t *testing.T) {
+	schema := tagschema.Default().AWS
+	values := schema.Resolve(map[string]string{"custodian_status": "Resource does not meet policy: delete@2025/01/02", "maid_status": "Resource does not meet policy: delete@2025/01/01"})
+	assert.Equal(t, "Resource does not meet policy: delete@2025/01/01", values.Expiry)
+	assert.Equal(t, "maid_status", values.Sources["expiry"])
+
+	values = schema.Resolve(map[string]string{"expiry": "2025-02-01", "maid_status": "Resource does not meet policy: delete@2025/01/01"})
+	assert.Equal(t, "2025-02-01", values.Expiry)
+}
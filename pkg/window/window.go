@@ -0,0 +1,210 @@
+package window
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Maximum window duration, limits the backward search of the window start
+const maxDuration = time.Hour * 24 * 7
+
+var ErrClosed = errors.New("maintenance window is closed")
+
+// Config is maintenance windows definition file content
+type Config struct {
+	// IANA time zone name, UTC by default
+	Timezone string       `json:"timezone"`
+	Windows  []Definition `json:"windows"`
+	// Dates in 2006-01-02 format when windows are closed
+	Exclude []string `json:"exclude"`
+}
+
+type Definition struct {
+	// Window start in cron format: minute hour day-of-month month day-of-week
+	Schedule string `json:"schedule"`
+	// Window length, e.g. 3h
+	Duration string `json:"duration"`
+}
+
+type Schedule struct {
+	location *time.Location
+	windows  []window
+	exclude  map[string]struct{}
+}
+
+type window struct {
+	spec     string
+	cron     cron
+	duration time.Duration
+}
+
+// Load reads maintenance windows definition JSON file
+func Load(file string) (*Schedule, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var config Config
+	if err := json.Unmarshal(content, &config); err != nil {
+		return nil, err
+	}
+	return New(config)
+}
+
+func New(config Config) (*Schedule, error) {
+	location, err := time.LoadLocation(config.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	s := &Schedule{location: location, exclude: make(map[string]struct{})}
+	for _, w := range config.Windows {
+		c, err := parseCron(w.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", w.Schedule, err)
+		}
+		duration, err := time.ParseDuration(w.Duration)
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration %q: %w", w.Duration, err)
+		}
+		if duration <= 0 || duration > maxDuration {
+			return nil, fmt.Errorf("duration %q must be positive and not longer than %s", w.Duration, maxDuration)
+		}
+		s.windows = append(s.windows, window{spec: w.Schedule, cron: c, duration: duration})
+	}
+	if len(s.windows) == 0 {
+		return nil, errors.New("no maintenance windows defined")
+	}
+	for _, d := range config.Exclude {
+		if _, err := time.Parse("2006-01-02", d); err != nil {
+			return nil, fmt.Errorf("invalid exclude date %q: %w", d, err)
+		}
+		s.exclude[d] = struct{}{}
+	}
+	return s, nil
+}
+
+// Check returns ErrClosed with the reason if the time is outside all windows or on excluded date
+func (s *Schedule) Check(t time.Time) error {
+	t = t.In(s.location)
+	if _, ok := s.exclude[t.Format("2006-01-02")]; ok {
+		return fmt.Errorf("%w: %s is excluded", ErrClosed, t.Format("2006-01-02"))
+	}
+	for _, w := range s.windows {
+		if w.isOpen(t) {
+			return nil
+		}
+	}
+	specs := make([]string, 0, len(s.windows))
+	for _, w := range s.windows {
+		specs = append(specs, fmt.Sprintf("%q for %s", w.spec, w.duration))
+	}
+	return fmt.Errorf("%w: %s is outside of windows %s (%s)", ErrClosed, t.Format(time.RFC3339), strings.Join(specs, ", "), s.location)
+}
+
+// Looks for window start minute in the past window duration
+func (w window) isOpen(t time.Time) bool {
+	start := t.Truncate(time.Minute)
+	for m := start; t.Sub(m) < w.duration; m = m.Add(-time.Minute) {
+		if w.cron.matches(m) {
+			return true
+		}
+	}
+	return false
+}
+
+type cron struct {
+	minute, hour, dom, month, dow fieldSet
+	domAny, dowAny                bool
+}
+
+type fieldSet map[int]struct{}
+
+func parseCron(spec string) (cron, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return cron{}, errors.New("expected 5 fields: minute hour day-of-month month day-of-week")
+	}
+	var (
+		c   cron
+		err error
+	)
+	if c.minute, err = parseField(fields[0], 0, 59); err != nil {
+		return cron{}, err
+	}
+	if c.hour, err = parseField(fields[1], 0, 23); err != nil {
+		return cron{}, err
+	}
+	if c.dom, err = parseField(fields[2], 1, 31); err != nil {
+		return cron{}, err
+	}
+	if c.month, err = parseField(fields[3], 1, 12); err != nil {
+		return cron{}, err
+	}
+	if c.dow, err = parseField(fields[4], 0, 7); err != nil {
+		return cron{}, err
+	}
+	// both 0 and 7 are Sunday
+	if _, ok := c.dow[7]; ok {
+		c.dow[0] = struct{}{}
+	}
+	c.domAny, c.dowAny = fields[2] == "*", fields[4] == "*"
+	return c, nil
+}
+
+// Parses comma separated list of `*`, `n`, `n-m` with optional `/step`
+func parseField(field string, min, max int) (fieldSet, error) {
+	set := make(fieldSet)
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step in %q", part)
+			}
+			rangePart = part[:i]
+		}
+		from, to := min, max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if from, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, fmt.Errorf("invalid value in %q", part)
+			}
+			to = from
+			if len(bounds) == 2 {
+				if to, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, fmt.Errorf("invalid value in %q", part)
+				}
+			}
+		}
+		if from < min || to > max || from > to {
+			return nil, fmt.Errorf("value out of range %d-%d in %q", min, max, part)
+		}
+		for v := from; v <= to; v += step {
+			set[v] = struct{}{}
+		}
+	}
+	return set, nil
+}
+
+func (c cron) matches(t time.Time) bool {
+	if !c.minute.has(t.Minute()) || !c.hour.has(t.Hour()) || !c.month.has(int(t.Month())) {
+		return false
+	}
+	domMatch, dowMatch := c.dom.has(t.Day()), c.dow.has(int(t.Weekday()))
+	// standard cron: if both day fields are restricted either of them matches
+	if !c.domAny && !c.dowAny {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
+
+func (s fieldSet) has(v int) bool {
+	_, ok := s[v]
+	return ok
+}
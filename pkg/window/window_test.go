@@ -0,0 +1,50 @@
+package window_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"c7n-helper/pkg/window"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	schedule, err := window.New(window.Config{
+		Timezone: "UTC",
+		Windows:  []window.Definition{{Schedule: "0 2 * * 1-5", Duration: "3h"}},
+		Exclude:  []string{"2025-01-15"},
+	})
+	assert.NoError(t, err)
+	// Tuesday
+	assert.NoError(t, schedule.Check(time.Date(2025, 1, 14, 2, 0, 0, 0, time.UTC)))
+	assert.NoError(t, schedule.Check(time.Date(2025, 1, 14, 4, 59, 0, 0, time.UTC)))
+	assert.True(t, errors.Is(schedule.Check(time.Date(2025, 1, 14, 5, 0, 0, 0, time.UTC)), window.ErrClosed))
+	assert.True(t, errors.Is(schedule.Check(time.Date(2025, 1, 14, 1, 59, 0, 0, time.UTC)), window.ErrClosed))
+	// excluded Wednesday
+	assert.True(t, errors.Is(schedule.Check(time.Date(2025, 1, 15, 3, 0, 0, 0, time.UTC)), window.ErrClosed))
+	// Saturday
+	assert.True(t, errors.Is(schedule.Check(time.Date(2025, 1, 18, 3, 0, 0, 0, time.UTC)), window.ErrClosed))
+}
+
+func TestCheckAcrossMidnight(t *testing.T) {
+	schedule, err := window.New(window.Config{
+		Timezone: "Europe/Berlin",
+		Windows:  []window.Definition{{Schedule: "30 23 * * 5", Duration: "2h"}},
+	})
+	assert.NoError(t, err)
+	// Saturday 00:30 in Berlin
+	assert.NoError(t, schedule.Check(time.Date(2025, 1, 17, 23, 30, 0, 0, time.UTC)))
+	assert.Error(t, schedule.Check(time.Date(2025, 1, 18, 0, 30, 0, 0, time.UTC)))
+}
+
+func TestNewInvalid(t *testing.T) {
+	_, err := window.New(window.Config{Windows: []window.Definition{{Schedule: "0 25 * * *", Duration: "1h"}}})
+	assert.Error(t, err)
+	_, err = window.New(window.Config{Windows: []window.Definition{{Schedule: "0 2 * *", Duration: "1h"}}})
+	assert.Error(t, err)
+	_, err = window.New(window.Config{Windows: []window.Definition{{Schedule: "0 2 * * *", Duration: "0s"}}})
+	assert.Error(t, err)
+	_, err = window.New(window.Config{})
+	assert.Error(t, err)
+}